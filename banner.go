@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// version is the tool version shown in banners, set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// renderBanner expands the Output.Banner template for the test of sourceFile into comment lines.
+// It fills the {{date}}, {{timestamp}}, {{model}}, {{version}} and {{source}} placeholders.
+func (tg *TestGenerator) renderBanner(sourceFile string, model string, now time.Time) string {
+	if model == "" {
+		model = tg.rules.ModelConfig.PrimaryModel
+	}
+	source := sourceFile
+	if relPath, err := filepath.Rel(tg.rules.Paths.CodebaseDir, sourceFile); err == nil {
+		source = filepath.ToSlash(relPath)
+	}
+
+	replacer := strings.NewReplacer(
+		"{{date}}", now.Format("2006-01-02"),
+		"{{timestamp}}", now.Format("2006-01-02 15:04:05"),
+		"{{model}}", model,
+		"{{version}}", version,
+		"{{source}}", source,
+	)
+
+	// Every line is a comment, so the banner never gets in the way of the includes below it
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(replacer.Replace(tg.rules.Output.Banner), "\n"), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "//") {
+			line = strings.TrimRight("// "+line, " ")
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// setBanner remembers the banner of a test file, so every later save of it keeps the banner
+func (tg *TestGenerator) setBanner(outputPath, banner string) {
+	tg.bannersMu.Lock()
+	defer tg.bannersMu.Unlock()
+	if tg.banners == nil {
+		tg.banners = make(map[string]string)
+	}
+	tg.banners[outputPath] = banner
+}
+
+// bannerFor returns the banner of a test file, if it has one
+func (tg *TestGenerator) bannerFor(outputPath string) string {
+	tg.bannersMu.Lock()
+	defer tg.bannersMu.Unlock()
+	return tg.banners[outputPath]
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchTestResult records the outcome of compiling and running one test file in a batch
+type batchTestResult struct {
+	TestFile    string
+	CompileErr  error
+	CompileTime time.Duration
+	Run         testRunOutput
+	InfoFile    string
+	Standards   []standardResult
+	Flaky       []string
+	Repeat      int
+}
+
+// status names the outcome of the test for the run log
+func (r batchTestResult) status() string {
+	switch {
+	case r.CompileErr != nil:
+		return "compile_failed"
+	case r.Run.Crashed:
+		return "crashed"
+	case r.Run.Err != nil:
+		return "failed"
+	default:
+		return "passed"
+	}
+}
+
+// batchWorkerCount returns the configured number of concurrent compile/run workers, lowered
+// to what the memory budget allows when Build.CompileMemoryMB is set
+func batchWorkerCount(rules *Rules, jobs int) int {
+	workers := rules.Build.Concurrency
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if limit, budget, ok := memoryWorkerLimit(rules); ok && limit < workers {
+		fmt.Printf("🧠 Memory budget of %d MB allows %d concurrent compiles of ~%d MB, using %d workers instead of %d\n",
+			budget, limit, rules.Build.CompileMemoryMB, limit, workers)
+		workers = limit
+	}
+	if workers > jobs {
+		workers = jobs
+	}
+	return workers
+}
+
+// memoryWorkerLimit returns how many compiles of Build.CompileMemoryMB fit in the memory budget,
+// never less than one, along with the budget. The budget is Build.MemoryBudgetMB, or the
+// memory currently available when that is 0. ok is false when there is no estimate or the
+// available memory can't be read.
+func memoryWorkerLimit(rules *Rules) (limit, budget int, ok bool) {
+	perCompile := rules.Build.CompileMemoryMB
+	if perCompile <= 0 {
+		return 0, 0, false
+	}
+	budget = rules.Build.MemoryBudgetMB
+	if budget <= 0 {
+		available, err := availableMemoryMB()
+		if err != nil {
+			log.Printf("Not limiting compiles by memory: %v", err)
+			return 0, 0, false
+		}
+		budget = available
+	}
+	limit = budget / perCompile
+	if limit < 1 {
+		limit = 1
+	}
+	return limit, budget, true
+}
+
+// availableMemoryMB returns the MemAvailable figure of /proc/meminfo in megabytes
+func availableMemoryMB() (int, error) {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read available memory: %v", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kb, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return 0, fmt.Errorf("failed to parse MemAvailable: %v", err)
+			}
+			return kb / 1024, nil
+		}
+	}
+	return 0, fmt.Errorf("MemAvailable not found in /proc/meminfo")
+}
+
+// RunCppTestBatch compiles and runs several test files concurrently, then reports their
+// results and the merged coverage. Every test builds and runs in its own working directory,
+// with GCOV_PREFIX pointing there, so that the .gcno/.gcda files of different tests never
+// clash; their coverage is captured per test and merged with lcov -a.
+func RunCppTestBatch(testFiles []string, sourceDir string, rules *Rules) error {
+	baseDir := rules.Paths.TempDir
+	if baseDir == "" {
+		baseDir = os.TempDir()
+	}
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return fmt.Errorf("failed to create temp directory: %v", err)
+	}
+
+	batchDir, err := os.MkdirTemp(baseDir, "utg-batch-")
+	if err != nil {
+		return fmt.Errorf("failed to create batch directory: %v", err)
+	}
+	defer os.RemoveAll(batchDir)
+
+	workers := batchWorkerCount(rules, len(testFiles))
+	fmt.Printf("🔨 Compiling and running %d test files with %d workers...\n", len(testFiles), workers)
+
+	jobs := make(chan int)
+	results := make([]batchTestResult, len(testFiles))
+	var printMu sync.Mutex
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = runBatchTest(testFiles[i], sourceDir, batchDir, rules)
+
+				printMu.Lock()
+				printBatchTestResult(results[i])
+				printMu.Unlock()
+			}
+		}()
+	}
+
+	for i := range testFiles {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// --- Aggregate Results ---
+	var infoFiles []string
+	passed, failed, crashed, compileFailed, flaky := 0, 0, 0, 0, 0
+	var counts testCounts
+	for _, result := range results {
+		flaky += len(result.Flaky)
+		counts.add(result.Run.Counts)
+		switch {
+		case result.CompileErr != nil:
+			compileFailed++
+		case result.Run.Crashed:
+			crashed++
+		case result.Run.Err != nil:
+			failed++
+		default:
+			passed++
+		}
+		if result.InfoFile != "" {
+			infoFiles = append(infoFiles, result.InfoFile)
+		}
+	}
+
+	fmt.Printf("\n🧪 Batch results: %d passed, %d failed, %d crashed, %d failed to compile\n",
+		passed, failed, crashed, compileFailed)
+	if counts.Found {
+		fmt.Printf("🧮 Test cases: %s\n", counts)
+	}
+	printTimingSummary(results)
+	if flaky > 0 {
+		fmt.Printf("⚠️  %d flaky tests detected across %d runs each\n", flaky, rules.Build.Repeat)
+	}
+
+	// --- Merged Coverage Report ---
+	testLog := newTestRunLog(results)
+	if len(infoFiles) > 0 {
+		fmt.Println("📊 Generating merged coverage summary...")
+		mergedInfoFile := filepath.Join(batchDir, "coverage.merged.info")
+		if err := MergeCoverageInfo(infoFiles, mergedInfoFile); err != nil {
+			fmt.Printf("⚠️  Coverage merge failed: %v\n", err)
+		} else if testLog.CoveredLines, testLog.TotalLines, err = ReportCoverage(mergedInfoFile, rules.Paths.TestsDir, sourceDir, rules); err != nil {
+			fmt.Printf("⚠️  Coverage summary generation failed: %v\n", err)
+		}
+	}
+	recordTestRun(testLog, rules)
+
+	if passed < len(results) {
+		return fmt.Errorf("%d of %d test files did not pass", len(results)-passed, len(results))
+	}
+
+	fmt.Println("✅ All test files passed!")
+	return nil
+}
+
+// runBatchTest compiles, runs and captures coverage for one test in an isolated directory under batchDir
+func runBatchTest(testFile string, sourceDir string, batchDir string, rules *Rules) batchTestResult {
+	result := batchTestResult{TestFile: testFile}
+
+	absTestFile, err := filepath.Abs(testFile)
+	if err != nil {
+		result.CompileErr = fmt.Errorf("failed to get absolute path for test file: %v", err)
+		return result
+	}
+
+	baseFile := strings.TrimSuffix(filepath.Base(testFile), filepath.Ext(testFile))
+	executableName := baseFile + "_executable"
+
+	workDir, err := os.MkdirTemp(batchDir, baseFile+"-")
+	if err != nil {
+		result.CompileErr = fmt.Errorf("failed to create working directory: %v", err)
+		return result
+	}
+
+	compileStart := time.Now()
+	err = compileCppTest(absTestFile, sourceDir, workDir, executableName, rules)
+	result.CompileTime = time.Since(compileStart)
+	if err != nil {
+		result.CompileErr = err
+		return result
+	}
+	result.Standards = checkStandards(absTestFile, sourceDir, rules)
+
+	result.Run = runTestExecutable(filepath.Join(workDir, executableName), workDir)
+	if rules.Build.Repeat > 1 && !result.Run.Crashed {
+		result.Repeat = rules.Build.Repeat
+		result.Flaky = detectFlakyTests(filepath.Join(workDir, executableName), workDir, result.Run, rules.Build.Repeat)
+	}
+
+	if rules.Build.NoCoverage {
+		return result
+	}
+
+	// Failing tests still contribute the coverage they reached
+	infoFile := filepath.Join(workDir, "coverage.info")
+	if err := CaptureCoverage(workDir, infoFile); err != nil {
+		fmt.Printf("⚠️  Coverage capture failed for %s: %v\n", testFile, err)
+	} else {
+		result.InfoFile = infoFile
+	}
+
+	return result
+}
+
+// printBatchTestResult writes the outcome of one batch test to the console
+func printBatchTestResult(result batchTestResult) {
+	switch {
+	case result.CompileErr != nil:
+		fmt.Printf("❌ %s: %v\n", result.TestFile, result.CompileErr)
+	case result.Run.Crashed:
+		fmt.Printf("💥 %s: crashed\n", result.TestFile)
+		if result.Run.Stderr != "" {
+			fmt.Printf("📛 Test stderr:\n%s\n", result.Run.Stderr)
+		}
+	case result.Run.Err != nil:
+		fmt.Printf("❌ %s: tests failed\n", result.TestFile)
+		fmt.Printf("📊 Test output:\n%s\n", result.Run.Stdout)
+	default:
+		fmt.Printf("✅ %s: passed\n", result.TestFile)
+	}
+	if result.Run.Counts.Found {
+		fmt.Printf("🧮 %s: %s\n", result.TestFile, result.Run.Counts)
+	}
+	if result.CompileErr == nil {
+		fmt.Printf("⏱️  %s: compile %v, run %v\n", result.TestFile,
+			result.CompileTime.Round(time.Millisecond), result.Run.Duration.Round(time.Millisecond))
+	}
+	printStandardResults(result.TestFile, result.Standards)
+	if len(result.Flaky) > 0 {
+		printFlakyTests(result.TestFile, result.Flaky, result.Repeat)
+	}
+}
+
+// MergeCoverageInfo combines several lcov info files into outputFile using lcov -a
+func MergeCoverageInfo(infoFiles []string, outputFile string) error {
+	if len(infoFiles) == 1 {
+		return copyFile(infoFiles[0], outputFile)
+	}
+
+	var lcovArgs []string
+	for _, infoFile := range infoFiles {
+		lcovArgs = append(lcovArgs, "--add-tracefile", infoFile)
+	}
+	lcovArgs = append(lcovArgs,
+		"--output-file", outputFile,
+		"--ignore-errors", "unsupported,inconsistent,unused",
+	)
+
+	mergeCmd := exec.Command("lcov", lcovArgs...)
+	if output, err := mergeCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("lcov merge failed: %v\nOutput: %s", err, string(output))
+	}
+
+	return nil
+}
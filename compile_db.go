@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// compileDBFile is the compilation database clangd looks for next to the sources
+const compileDBFile = "compile_commands.json"
+
+// compileCommand is one entry of a compilation database
+type compileCommand struct {
+	Directory string   `json:"directory"`
+	File      string   `json:"file"`
+	Arguments []string `json:"arguments"`
+}
+
+// updateCompileDB adds or replaces the compilation database entry for a test file in TestsDir,
+// using the flags the test is compiled with so editors resolve its includes
+func (tg *TestGenerator) updateCompileDB(testFile string) error {
+	absTestFile, err := filepath.Abs(testFile)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for %s: %v", testFile, err)
+	}
+
+	// The precompiled header is left out, editors parse the headers themselves
+	flags, err := testFileCompileArgs(absTestFile, tg.rules.Paths.CodebaseDir, tg.rules)
+	if err != nil {
+		return err
+	}
+
+	entry := compileCommand{
+		Directory: filepath.Dir(absTestFile),
+		File:      absTestFile,
+		Arguments: append(append([]string{"g++"}, flags...), "-c", absTestFile),
+	}
+
+	tg.compileDBMu.Lock()
+	defer tg.compileDBMu.Unlock()
+
+	dbPath := filepath.Join(tg.rules.Paths.TestsDir, compileDBFile)
+	var entries []compileCommand
+	if data, err := os.ReadFile(dbPath); err == nil {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", dbPath, err)
+		}
+	}
+
+	replaced := false
+	for i := range entries {
+		if entries[i].File == entry.File {
+			entries[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].File < entries[j].File })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode compilation database: %v", err)
+	}
+	if err := os.WriteFile(dbPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", dbPath, err)
+	}
+	tg.recordSupportFile(dbPath)
+	return nil
+}
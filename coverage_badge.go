@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// coverageBadgeTemplate is a flat shields-style badge; it takes the total width, the
+// message width, the color, the label and message centers and the message
+const coverageBadgeTemplate = `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="coverage: %[6]s">
+  <title>coverage: %[6]s</title>
+  <linearGradient id="s" x2="0" y2="100%%">
+    <stop offset="0" stop-color="#bbb" stop-opacity=".1"/>
+    <stop offset="1" stop-opacity=".1"/>
+  </linearGradient>
+  <clipPath id="r">
+    <rect width="%[1]d" height="20" rx="3" fill="#fff"/>
+  </clipPath>
+  <g clip-path="url(#r)">
+    <rect width="%[7]d" height="20" fill="#555"/>
+    <rect x="%[7]d" width="%[2]d" height="20" fill="%[3]s"/>
+    <rect width="%[1]d" height="20" fill="url(#s)"/>
+  </g>
+  <g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+    <text x="%[4]d" y="15" fill="#010101" fill-opacity=".3">coverage</text>
+    <text x="%[4]d" y="14">coverage</text>
+    <text x="%[5]d" y="15" fill="#010101" fill-opacity=".3">%[6]s</text>
+    <text x="%[5]d" y="14">%[6]s</text>
+  </g>
+</svg>
+`
+
+// coverageBadgeColor returns the badge color for a coverage percentage: red below 50%,
+// yellow below 80% and green from there
+func coverageBadgeColor(percentage float64) string {
+	switch {
+	case percentage < 50:
+		return "#e05d44"
+	case percentage < 80:
+		return "#dfb317"
+	default:
+		return "#4c1"
+	}
+}
+
+// WriteCoverageBadge writes a self-contained SVG badge showing the coverage percentage
+func WriteCoverageBadge(percentage float64, outputPath string) error {
+	message := fmt.Sprintf("%.1f%%", percentage)
+	// Verdana at 11px averages about 7px per character, plus 5px padding on each side
+	const labelWidth = 61
+	messageWidth := 7*len(message) + 10
+	width := labelWidth + messageWidth
+
+	svg := fmt.Sprintf(coverageBadgeTemplate, width, messageWidth, coverageBadgeColor(percentage),
+		labelWidth/2, labelWidth+messageWidth/2, message, labelWidth)
+	if err := os.WriteFile(outputPath, []byte(svg), 0644); err != nil {
+		return fmt.Errorf("failed to write coverage badge: %v", err)
+	}
+	return nil
+}
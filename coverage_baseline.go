@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// coverageBaselinePath returns where the coverage accumulated across runs is kept
+func coverageBaselinePath(rules *Rules) string {
+	baseDir := rules.Paths.TempDir
+	if baseDir == "" {
+		baseDir = os.TempDir()
+	}
+	return filepath.Join(baseDir, "coverage", "baseline.info")
+}
+
+// accumulateCoverage merges a run's lcov info file into the coverage baseline when
+// Coverage.Accumulate is set and returns the file to report from: the updated baseline, or
+// infoFile itself when accumulation is off or the merge fails.
+func accumulateCoverage(infoFile string, rules *Rules) string {
+	if !rules.Coverage.Accumulate {
+		return infoFile
+	}
+
+	baseline := coverageBaselinePath(rules)
+	if err := os.MkdirAll(filepath.Dir(baseline), 0755); err != nil {
+		fmt.Printf("⚠️  Failed to create coverage baseline directory, reporting this run only: %v\n", err)
+		return infoFile
+	}
+
+	if _, err := os.Stat(baseline); err != nil {
+		if err := copyFile(infoFile, baseline); err != nil {
+			fmt.Printf("⚠️  Failed to start coverage baseline, reporting this run only: %v\n", err)
+			return infoFile
+		}
+		fmt.Printf("📈 Started coverage baseline: %s\n", baseline)
+		return baseline
+	}
+
+	// Merge into a temporary file, so a failed merge leaves the baseline intact
+	merged := baseline + ".tmp"
+	defer os.Remove(merged)
+	if err := MergeCoverageInfo([]string{baseline, infoFile}, merged); err != nil {
+		fmt.Printf("⚠️  Failed to merge into the coverage baseline, reporting this run only (use -reset-coverage after major source changes): %v\n", err)
+		return infoFile
+	}
+	if err := os.Rename(merged, baseline); err != nil {
+		fmt.Printf("⚠️  Failed to update coverage baseline, reporting this run only: %v\n", err)
+		return infoFile
+	}
+	fmt.Printf("📈 Coverage merged into the baseline of earlier runs: %s\n", baseline)
+	return baseline
+}
+
+// ResetCoverageBaseline deletes the coverage accumulated by earlier runs
+func ResetCoverageBaseline(rules *Rules) error {
+	baseline := coverageBaselinePath(rules)
+	if err := os.Remove(baseline); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove coverage baseline: %v", err)
+	}
+	fmt.Printf("🧹 Coverage baseline cleared: %s\n", baseline)
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MeasureTestCoverage compiles and runs a single test in an isolated directory and returns the
+// coverage it reaches for each source file, keyed by absolute path
+func MeasureTestCoverage(testFile string, sourceDir string, rules *Rules) (map[string]*FileCoverage, error) {
+	baseDir := rules.Paths.TempDir
+	if baseDir == "" {
+		baseDir = os.TempDir()
+	}
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+
+	workDir, err := os.MkdirTemp(baseDir, "utg-coverage-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create working directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	result := runBatchTest(testFile, sourceDir, workDir, withCoverage(rules))
+	if result.CompileErr != nil {
+		return nil, result.CompileErr
+	}
+	if result.InfoFile == "" {
+		return nil, fmt.Errorf("no coverage captured for %s", testFile)
+	}
+
+	return ParseCoverageInfo(result.InfoFile, sourceDir, rules.Coverage.ExcludeFunctions)
+}
+
+// improveCoverage regenerates the test for filename while the source's coverage stays below
+// Coverage.MinimumThreshold, asking the model to extend the tests with the functions they
+// missed. Each round recompiles and re-measures; a round that doesn't raise coverage is
+// discarded. It stops after Coverage.ImprovementRounds rounds and keeps the best tests.
+func (tg *TestGenerator) improveCoverage(ctx context.Context, filename, content, outputPath, testCode string, focusFunctions []string) {
+	absSourceFile, err := filepath.Abs(filename)
+	if err != nil {
+		log.Printf("Skipping coverage improvement for %s: %v", filename, err)
+		return
+	}
+
+	rounds := tg.rules.Coverage.ImprovementRounds
+	bestCode, best := testCode, -1.0
+	for round := 0; ; round++ {
+		coverage, err := MeasureTestCoverage(outputPath, tg.rules.Paths.CodebaseDir, tg.rules)
+		if err != nil {
+			fmt.Printf("⚠️  Coverage improvement for %s stopped: %v\n", filename, err)
+			break
+		}
+
+		fileCoverage, ok := coverage[absSourceFile]
+		if !ok {
+			log.Printf("No coverage data for %s, skipping coverage improvement", filename)
+			break
+		}
+		percentage := fileCoverage.Percentage()
+		if percentage <= best {
+			log.Printf("Round %d did not improve coverage of %s (%.2f%%)", round, filename, percentage)
+			break
+		}
+		bestCode, best = testCode, percentage
+
+		if percentage >= tg.rules.Coverage.MinimumThreshold {
+			if round > 0 {
+				fmt.Printf("📈 %s reached %.2f%% coverage\n", filename, percentage)
+			}
+			return
+		}
+
+		uncovered := uncoveredFunctionNames(fileCoverage)
+		if round == rounds || len(uncovered) == 0 {
+			break
+		}
+
+		fmt.Printf("📈 %s is at %.2f%% coverage (minimum %.2f%%), improvement round %d/%d...\n",
+			filename, percentage, tg.rules.Coverage.MinimumThreshold, round+1, rounds)
+
+		extraPrompt := "The existing tests below leave these functions uncovered: " + strings.Join(uncovered, ", ") +
+			". Return the complete test file: keep every existing test and add tests that call the uncovered functions.\n\n" +
+			"Existing tests:\n" + testCode
+
+		improved, metrics, err := tg.GenerateUnitTests(ctx, filename, content, extraPrompt, focusFunctions)
+		tg.recordMetrics(filename, metrics)
+		if err != nil {
+			fmt.Printf("⚠️  Coverage improvement for %s failed: %v\n", filename, err)
+			break
+		}
+
+		testCode = tg.finishTestCode(improved, filename, content)
+		if err := tg.saveTestFile(outputPath, testCode); err != nil {
+			fmt.Printf("⚠️  Failed to save improved test %s: %v\n", outputPath, err)
+			break
+		}
+	}
+
+	// Keep the tests with the best coverage reached
+	if testCode != bestCode {
+		if err := tg.saveTestFile(outputPath, bestCode); err != nil {
+			fmt.Printf("⚠️  Failed to restore %s: %v\n", outputPath, err)
+		}
+	}
+	if best >= 0 {
+		fmt.Printf("⚠️  %s stays at %.2f%% coverage, below the %.2f%% minimum\n", filename, best, tg.rules.Coverage.MinimumThreshold)
+	}
+}
+
+// uncoveredFunctionNames returns the readable names of the functions a file's tests never call
+func uncoveredFunctionNames(coverage *FileCoverage) []string {
+	var mangled []string
+	for _, function := range coverage.UncoveredFunctions() {
+		mangled = append(mangled, function.Name)
+	}
+
+	readable := demangleNames(mangled)
+	names := make([]string, 0, len(mangled))
+	for _, name := range mangled {
+		names = append(names, readable[name])
+	}
+	sort.Strings(names)
+	return names
+}
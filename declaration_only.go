@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Values of TestCaseRules.DeclarationOnly, what happens to files that declare only types
+const (
+	declarationOnlyGenerate     = "generate"
+	declarationOnlySkip         = "skip"
+	declarationOnlyStaticAssert = "static_assert"
+)
+
+// declarationOnlyMode returns the configured handling of declaration-only files, generating
+// tests as for any other file by default
+func (tg *TestGenerator) declarationOnlyMode() string {
+	switch mode := strings.ToLower(strings.TrimSpace(tg.rules.TestCaseRules.DeclarationOnly)); mode {
+	case declarationOnlySkip, declarationOnlyStaticAssert:
+		return mode
+	default:
+		return declarationOnlyGenerate
+	}
+}
+
+// groupDeclaresOnlyTypes reports whether none of a group's own files has a function, method
+// or constructor to test. Context headers added to the prompt don't count.
+func (tg *TestGenerator) groupDeclaresOnlyTypes(group map[string]string) bool {
+	for filename, content := range group {
+		if !tg.sourceInfo(filename, content).DeclarationsOnly() {
+			return false
+		}
+	}
+	return true
+}
+
+// staticAssertPrompt asks for compile-time checks of the types a declaration-only group
+// declares, instead of behavioral tests
+func (tg *TestGenerator) staticAssertPrompt(group map[string]string) string {
+	var types []string
+	for filename, content := range group {
+		types = append(types, tg.sourceInfo(filename, content).ClassNames()...)
+	}
+	sort.Strings(types)
+
+	prompt := "The code only declares types and has no functions to test. Don't call or test any behavior. " +
+		"Instead write a single test case holding static_assert checks of the declared types: enumerator values, " +
+		"underlying types of enums, sizes and triviality where the code relies on them, and that aliases name the intended type."
+	if len(types) > 0 {
+		prompt += fmt.Sprintf(" The declared types include: %s.", strings.Join(types, ", "))
+	}
+	return prompt
+}
+
+// declarationOnlyNotice reports how a declaration-only file is handled
+func declarationOnlyNotice(filename, mode string) {
+	switch mode {
+	case declarationOnlySkip:
+		fmt.Printf("⏭️  %s only declares types, skipping it\n", filepath.Base(filename))
+	case declarationOnlyStaticAssert:
+		fmt.Printf("🧩 %s only declares types, generating static_assert checks\n", filepath.Base(filename))
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Values of Output.EmptyTests, what happens when a file ends up without any test case
+const (
+	emptyTestsFail        = "fail"
+	emptyTestsSkip        = "skip"
+	emptyTestsPlaceholder = "placeholder"
+)
+
+// errNoTestsWritten marks a file that was skipped because it got no tests
+var errNoTestsWritten = errors.New("no tests written")
+
+// emptyTestsMode returns the configured handling of empty test files, failing by default
+func (tg *TestGenerator) emptyTestsMode() string {
+	switch mode := strings.ToLower(strings.TrimSpace(tg.rules.Output.EmptyTests)); mode {
+	case emptyTestsSkip, emptyTestsPlaceholder:
+		return mode
+	default:
+		return emptyTestsFail
+	}
+}
+
+// isEmptyTestsCause reports whether a generation failure means the model gave no usable tests,
+// a response that was empty or rejected by a validator, which Output.EmptyTests applies to.
+// Other failures, like an unreachable server, are always reported.
+func isEmptyTestsCause(err error) bool {
+	var valErr *validationError
+	return errors.As(err, &valErr) || errors.Is(err, errEmptyResponse)
+}
+
+// handleEmptyTests applies Output.EmptyTests to a file that got no usable tests because of
+// cause. It returns cause when failing, errNoTestsWritten when skipping or when a test file
+// already exists at outputPath, and nil once a placeholder test has been saved.
+func (tg *TestGenerator) handleEmptyTests(filename, outputPath string, cause error) error {
+	switch tg.emptyTestsMode() {
+	case emptyTestsSkip:
+		fmt.Printf("⏭️  %s: %v, no test file written\n", filename, cause)
+		return errNoTestsWritten
+	case emptyTestsPlaceholder:
+		if _, err := os.Stat(outputPath); err == nil {
+			// A placeholder is never better than the tests already there
+			fmt.Printf("⏭️  %s: %v, keeping the existing %s\n", filename, cause, outputPath)
+			return errNoTestsWritten
+		}
+		fmt.Printf("⚠️  %s: %v, writing a skipped placeholder test to %s\n", filename, cause, outputPath)
+		if err := tg.saveTestFile(outputPath, tg.placeholderTest(filename)); err != nil {
+			return fmt.Errorf("failed to save placeholder test: %v", err)
+		}
+		return nil
+	default:
+		return cause
+	}
+}
+
+// placeholderTest returns a test file with a single skipped test, which keeps the test target
+// buildable until real tests are generated
+func (tg *TestGenerator) placeholderTest(filename string) string {
+	base := filepath.Base(filename)
+	message := fmt.Sprintf("No tests were generated for %s", base)
+	return tg.framework.MainInclude + "\n\n" + fmt.Sprintf(tg.framework.SkippedTest, placeholderSuiteName(base), message)
+}
+
+// placeholderSuiteName turns a file name like "geo_shape.cpp" into a suite name like "GeoShapePlaceholder"
+func placeholderSuiteName(base string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range strings.TrimSuffix(base, filepath.Ext(base)) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String() + "Placeholder"
+	if unicode.IsDigit(rune(name[0])) {
+		name = "File" + name
+	}
+	return name
+}
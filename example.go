@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+// builtinExampleSource is the class shown in the prompt when no example pair is configured
+const builtinExampleSource = `class Counter {
+public:
+    explicit Counter(int start = 0) : value_(start) {}
+    void increment() { ++value_; }
+    int value() const { return value_; }
+private:
+    int value_;
+};`
+
+// builtinExampleTests are the matching tests for builtinExampleSource, per framework
+var builtinExampleTests = map[string]string{
+	"Google Test": `#include <gtest/gtest.h>
+#include "counter.h"
+
+TEST(CounterTest, StartsAtGivenValue) {
+    Counter counter(5);
+    EXPECT_EQ(counter.value(), 5);
+}
+
+TEST(CounterTest, IncrementAddsOne) {
+    Counter counter;
+    counter.increment();
+    EXPECT_EQ(counter.value(), 1);
+}`,
+	"Catch2": `#include <catch2/catch_test_macros.hpp>
+#include "counter.h"
+
+TEST_CASE("Counter starts at the given value", "[Counter]") {
+    Counter counter(5);
+    CHECK(counter.value() == 5);
+}
+
+TEST_CASE("Counter increment adds one", "[Counter]") {
+    Counter counter;
+    counter.increment();
+    CHECK(counter.value() == 1);
+}`,
+}
+
+// promptExample returns the example source and test shown to the model. A configured example
+// pair is read once; the built-in example for the framework is used when none is configured
+// or it can't be read.
+func (tg *TestGenerator) promptExample() (string, string) {
+	tg.exampleOnce.Do(func() {
+		tg.exampleSource = builtinExampleSource
+		tg.exampleTest = builtinExampleTests[tg.framework.Name]
+
+		format := tg.rules.OutputFormat
+		if format.ExampleSource == "" || format.ExampleTest == "" {
+			return
+		}
+
+		source, err := os.ReadFile(format.ExampleSource)
+		if err != nil {
+			log.Printf("Failed to read example source, using the built-in example: %v", err)
+			return
+		}
+		test, err := os.ReadFile(format.ExampleTest)
+		if err != nil {
+			log.Printf("Failed to read example test, using the built-in example: %v", err)
+			return
+		}
+		tg.exampleSource, tg.exampleTest = string(source), string(test)
+	})
+
+	return tg.exampleSource, tg.exampleTest
+}
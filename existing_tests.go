@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxExistingTestBytes bounds the existing tests added to one prompt
+const maxExistingTestBytes = 16 * 1024
+
+// handWrittenTestFiles lists the test files in TestSearchDirs that weren't generated by utg,
+// once per run
+func (tg *TestGenerator) handWrittenTestFiles() []string {
+	tg.existingTestsOnce.Do(func() {
+		var dirs []string
+		for _, dir := range tg.rules.TestSearchDirs() {
+			if _, err := os.Stat(dir); err == nil {
+				dirs = append(dirs, dir)
+			}
+		}
+		if len(dirs) == 0 {
+			return
+		}
+		files, err := ListCppTestFilesIn(dirs)
+		if err != nil {
+			log.Printf("Failed to list existing tests: %v", err)
+			return
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil || strings.Contains(string(data), generatedMarkerText) || tg.manifestListsTest(file) {
+				continue
+			}
+			tg.existingTests = append(tg.existingTests, file)
+		}
+		sort.Strings(tg.existingTests)
+	})
+	return tg.existingTests
+}
+
+// testFileStem returns the name a test file is for, following the _test.cpp, test.cpp,
+// _test.cc and test.cc conventions of ListCppTestFiles: "shape_test.cc" is for "shape"
+func testFileStem(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	name = strings.TrimSuffix(name, "test")
+	return strings.TrimRight(name, "_-.")
+}
+
+// existingTestsFor returns the hand-written tests of a source file: the tests in a test
+// directory whose path below it mirrors the generated test's, "geo/shape_test.cpp" for
+// "geo/shape.cpp"
+func (tg *TestGenerator) existingTestsFor(filename string) []string {
+	want := tg.generateTestFilename(filename)
+	wantDir, wantStem := filepath.Dir(want), testFileStem(want)
+
+	var matches []string
+	for _, file := range tg.handWrittenTestFiles() {
+		relPath, ok := tg.relToTestSearchDir(file)
+		if ok && filepath.Dir(relPath) == wantDir && testFileStem(relPath) == wantStem {
+			matches = append(matches, file)
+		}
+	}
+	return matches
+}
+
+// relToTestSearchDir returns a test file's path relative to the test directory it was found in
+func (tg *TestGenerator) relToTestSearchDir(file string) (string, bool) {
+	for _, dir := range tg.rules.TestSearchDirs() {
+		relPath, err := filepath.Rel(dir, file)
+		if err == nil && relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+			return relPath, true
+		}
+	}
+	return "", false
+}
+
+// testOutputPath returns where the test of a source file is saved. With
+// LLMPromptGuidance.ExistingTests the model only writes the tests missing from the hand-written
+// ones, so when a hand-written test already sits at the usual path the new tests go next to it,
+// "shape_generated_test.cc", instead of replacing it.
+func (tg *TestGenerator) testOutputPath(filename string) string {
+	outputPath := filepath.Join(tg.rules.Paths.TestsDir, tg.generateTestFilename(filename))
+	if !tg.rules.LLMPromptGuidance.ExistingTests {
+		return outputPath
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil || strings.Contains(string(data), generatedMarkerText) || tg.manifestListsTest(outputPath) {
+		return outputPath
+	}
+	alternate := strings.TrimSuffix(outputPath, "_test.cc") + "_generated_test.cc"
+	log.Printf("%s is hand-written, saving the tests of %s to %s", outputPath, filename, alternate)
+	return alternate
+}
+
+// existingTestsPrompt returns the hand-written tests of a source file as prompt context, asking
+// the model to match their style and cover what they don't. It returns "" when there are none
+// or LLMPromptGuidance.ExistingTests is off.
+func (tg *TestGenerator) existingTestsPrompt(filename string) string {
+	if !tg.rules.LLMPromptGuidance.ExistingTests {
+		return ""
+	}
+
+	var prompt strings.Builder
+	remaining := maxExistingTestBytes
+	for _, file := range tg.existingTestsFor(filename) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		content := string(data)
+		if len(content) > remaining {
+			log.Printf("Leaving %s out of the prompt, the existing tests exceed %d bytes", file, maxExistingTestBytes)
+			continue
+		}
+		remaining -= len(content)
+		prompt.WriteString(fmt.Sprintf("\n// Existing tests: %s\n%s\n", file, strings.TrimSpace(content)))
+	}
+	if prompt.Len() == 0 {
+		return ""
+	}
+
+	log.Printf("Adding existing tests of %s to the prompt", filename)
+	return "This code already has hand-written tests, shown below. Match their style, naming, fixtures and helpers, " +
+		"and write tests that complement them: cover what they leave out and don't repeat cases they already test. " +
+		"Return only the new tests as a complete file; it is saved next to the existing ones, which stay as they are.\n" + prompt.String()
+}
@@ -1,17 +1,96 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
-// ReadCodebase reads all C++ files from the specified directory, but only from folders listed in toScan
-func ReadCodebase(dir string, toScan []string) (map[string]string, error) {
-	filesContent := make(map[string]string)
+// maxSymlinkWalkDepth bounds directory nesting when following symlinks
+const maxSymlinkWalkDepth = 64
+
+// walkFollowingSymlinks behaves like filepath.Walk but descends into symlinked directories.
+// Directories already visited (by device and inode) are skipped so symlink cycles can't loop forever.
+// Broken symlinks below root are logged and skipped.
+func walkFollowingSymlinks(root string, walkFn filepath.WalkFunc) error {
+	var visited []os.FileInfo
+
+	var walk func(path string, depth int) error
+	walk = func(path string, depth int) error {
+		info, err := os.Stat(path)
+		if err != nil {
+			// A dangling symlink is no reason to give up on the rest of the tree
+			if linkInfo, lerr := os.Lstat(path); lerr == nil && linkInfo.Mode()&os.ModeSymlink != 0 && depth > 0 {
+				log.Printf("Skipping broken symlink %s: %v", path, err)
+				return nil
+			}
+			return walkFn(path, nil, err)
+		}
+
+		if !info.IsDir() {
+			return walkFn(path, info, nil)
+		}
+
+		for _, seen := range visited {
+			if os.SameFile(seen, info) {
+				log.Printf("Skipping already visited directory (symlink loop?): %s", path)
+				return nil
+			}
+		}
+		visited = append(visited, info)
+
+		if depth > maxSymlinkWalkDepth {
+			log.Printf("Skipping %s: exceeds maximum walk depth of %d", path, maxSymlinkWalkDepth)
+			return nil
+		}
+
+		if err := walkFn(path, info, nil); err != nil {
+			if err == filepath.SkipDir {
+				return nil
+			}
+			return err
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return walkFn(path, info, err)
+		}
+
+		for _, entry := range entries {
+			if err := walk(filepath.Join(path, entry.Name()), depth+1); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return walk(root, 0)
+}
+
+// walkCodebase walks dir, following symlinks when requested
+func walkCodebase(dir string, followSymlinks bool, walkFn filepath.WalkFunc) error {
+	if followSymlinks {
+		return walkFollowingSymlinks(dir, walkFn)
+	}
+	return filepath.Walk(dir, walkFn)
+}
+
+// ReadCodebase reads all C/C++ files from the specified directory, but only from folders listed in toScan.
+// Files with an extension of one of the configured language profiles are read too. The directory is
+// walked first and the files are then read by readWorkers concurrent workers (0 = number of CPUs).
+func ReadCodebase(dir string, toScan []string, followSymlinks bool, profiles map[string]LanguageProfile, readWorkers int) (map[string]string, error) {
+	var found []codebaseFile
 	log.Printf("Reading codebase directory: %s", dir)
 	log.Printf("Scanning only folders: %v", toScan)
 
@@ -28,7 +107,7 @@ func ReadCodebase(dir string, toScan []string) (map[string]string, error) {
 		foldersToScan[folder] = true
 	}
 
-	err = filepath.Walk(absDir, func(path string, info os.FileInfo, err error) error {
+	err = walkCodebase(absDir, followSymlinks, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			log.Printf("Error accessing path %s: %v", path, err)
 			return err
@@ -39,8 +118,8 @@ func ReadCodebase(dir string, toScan []string) (map[string]string, error) {
 			return nil
 		}
 
-		// Only process C++ files
-		if !isCppFile(info.Name()) {
+		// Only process source and header files
+		if _, configured := profiles[strings.ToLower(filepath.Ext(info.Name()))]; !configured && !isCppFile(info.Name()) {
 			return nil
 		}
 
@@ -75,127 +154,195 @@ func ReadCodebase(dir string, toScan []string) (map[string]string, error) {
 		// Store with relative path from the base directory (fixed)
 		relativePath := filepath.Join(dir, relPath)
 		log.Printf("Found file: %s", relativePath)
-
-		content, err := os.ReadFile(path)
-		if err != nil {
-			log.Printf("Error reading file %s: %v", path, err)
-			return err
-		}
-
-		filesContent[relativePath] = string(content)
-		log.Printf("Successfully read file %s (%d bytes)", relativePath, len(content))
+		found = append(found, codebaseFile{Path: path, Name: relativePath})
 		return nil
 	})
 
 	if err != nil {
 		log.Printf("Failed to walk codebase directory %s: %v", dir, err)
-	} else {
+	}
+
+	filesContent := readCodebaseFiles(found, readWorkers)
+	if err == nil {
 		log.Printf("Found %d files in codebase", len(filesContent))
 	}
 
 	return filesContent, err
 }
 
-// isCppFile checks if a file is a C++ source or header file
-func isCppFile(filename string) bool {
-	return strings.HasSuffix(filename, ".cpp") || strings.HasSuffix(filename, ".h")
+// codebaseFile is a file found while walking the codebase: its path on disk and the name it's stored under
+type codebaseFile struct {
+	Path string
+	Name string
 }
 
-// CopyHeaderFiles copies all .h files from the codebase to the tests directory
-func CopyHeaderFiles(codebaseDir, testsDir string, foldersToScan []string) error {
-	log.Printf("Copying header files from %s to %s", codebaseDir, testsDir)
-
-	// Convert to absolute paths for consistent handling
-	absCodebaseDir, err := filepath.Abs(codebaseDir)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path for %s: %v", codebaseDir, err)
+// readCodebaseFiles reads files with a pool of workers. Files that can't be read or aren't
+// text are skipped with a warning, so one bad file doesn't stop the rest from being read.
+func readCodebaseFiles(files []codebaseFile, workers int) map[string]string {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(files) {
+		workers = len(files)
 	}
 
-	absTestsDir, err := filepath.Abs(testsDir)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path for %s: %v", testsDir, err)
+	filesContent := make(map[string]string, len(files))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan codebaseFile)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				content, err := os.ReadFile(file.Path)
+				if err != nil {
+					fmt.Printf("⚠️  Skipping %s: %v\n", file.Name, err)
+					continue
+				}
+
+				// Binary or non-UTF-8 content would corrupt the prompt
+				if !isTextContent(content) {
+					fmt.Printf("⚠️  Skipping %s: not valid UTF-8 text\n", file.Name)
+					continue
+				}
+
+				mu.Lock()
+				filesContent[file.Name] = string(content)
+				mu.Unlock()
+				log.Printf("Successfully read file %s (%d bytes)", file.Name, len(content))
+			}
+		}()
 	}
 
-	// Create a map for quick lookup of folders to scan
-	foldersToScanMap := make(map[string]bool)
-	for _, folder := range foldersToScan {
-		foldersToScanMap[folder] = true
+	for _, file := range files {
+		jobs <- file
 	}
+	close(jobs)
+	wg.Wait()
 
-	copiedCount := 0
+	return filesContent
+}
 
-	err = filepath.Walk(absCodebaseDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			log.Printf("Error accessing path %s: %v", path, err)
-			return err
-		}
+// matchGlob reports whether a slash-separated name matches a glob pattern. Besides the
+// path.Match syntax, a "**" segment matches any number of path segments.
+func matchGlob(pattern, name string) bool {
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
 
-		// Skip if it's a directory
-		if info.IsDir() {
-			return nil
+// matchGlobSegments matches pattern segments against name segments, expanding "**"
+func matchGlobSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlobSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
 		}
 
-		// Only process header files
-		if !isHeaderFile(info.Name()) {
-			return nil
+		if len(name) == 0 {
+			return false
 		}
-
-		// Get the relative path from the base directory
-		relPath, err := filepath.Rel(absCodebaseDir, path)
-		if err != nil {
-			log.Printf("Error getting relative path for %s: %v", path, err)
-			return err
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
 		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
 
-		// Check if the file is in one of the folders we want to scan
-		pathParts := strings.Split(relPath, string(filepath.Separator))
-		if len(pathParts) == 0 {
-			return nil
-		}
+// isTextContent checks that file content is valid UTF-8 without NUL bytes
+func isTextContent(content []byte) bool {
+	return utf8.Valid(content) && !bytes.Contains(content, []byte{0})
+}
 
-		// Determine the folder to check
-		var folderToCheck string
-		if len(pathParts) == 1 {
-			// File is directly in the root directory
-			folderToCheck = "."
-		} else {
-			// File is in a subdirectory, check the first directory
-			folderToCheck = pathParts[0]
-		}
+// Supported line-ending styles
+const (
+	lineEndingsLF       = "lf"
+	lineEndingsCRLF     = "crlf"
+	lineEndingsPreserve = "preserve"
+)
 
-		if !foldersToScanMap[folderToCheck] {
-			// Skip this file as it's not in a folder we want to scan
-			return nil
+// lineEndingStyle returns the configured line-ending style, defaulting to the platform's native one
+func lineEndingStyle(configured string) string {
+	switch style := strings.ToLower(strings.TrimSpace(configured)); style {
+	case lineEndingsLF, lineEndingsCRLF, lineEndingsPreserve:
+		return style
+	case "":
+		if runtime.GOOS == "windows" {
+			return lineEndingsCRLF
 		}
+		return lineEndingsLF
+	default:
+		log.Printf("Unknown line_endings %q, using lf", configured)
+		return lineEndingsLF
+	}
+}
 
-		// Create destination path
-		destPath := filepath.Join(absTestsDir, relPath)
-
-		// Create destination directory if it doesn't exist
-		destDir := filepath.Dir(destPath)
-		if err := os.MkdirAll(destDir, 0755); err != nil {
-			log.Printf("Error creating destination directory %s: %v", destDir, err)
-			return err
-		}
+// normalizeLineEndings converts all line endings in content to the given style
+func normalizeLineEndings(content, style string) string {
+	if style == lineEndingsPreserve {
+		return content
+	}
 
-		// Copy the file
-		if err := copyFile(path, destPath); err != nil {
-			log.Printf("Error copying file %s to %s: %v", path, destPath, err)
-			return err
-		}
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+	if style == lineEndingsCRLF {
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+	return content
+}
 
-		log.Printf("Copied header file: %s -> %s", path, destPath)
-		copiedCount++
+// isCppFile checks if a file is a C/C++ source or header file
+func isCppFile(filename string) bool {
+	_, isSource := builtinLanguageProfiles[strings.ToLower(filepath.Ext(filename))]
+	return isSource || isHeaderFile(filename)
+}
 
-		return nil
-	})
+// fallbackTestsDirName is the directory under the temp directory used when neither TestsDir
+// nor Paths.FallbackTestsDir can be written to
+const fallbackTestsDirName = "utg-tests"
 
+// checkWritableDir creates dir if needed and checks that files can be written into it
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(dir, ".utg-write-check-")
 	if err != nil {
-		return fmt.Errorf("failed to copy header files: %v", err)
+		return err
 	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
 
-	log.Printf("Successfully copied %d header files", copiedCount)
-	return nil
+// ResolveTestsDir makes sure generated tests can be written. When TestsDir can't be created or
+// written to, Paths.FallbackTestsDir, or else a directory under the temp directory, replaces it
+// in rules; the directory in use is returned.
+func ResolveTestsDir(rules *Rules) (string, error) {
+	primaryErr := checkWritableDir(rules.Paths.TestsDir)
+	if primaryErr == nil {
+		return rules.Paths.TestsDir, nil
+	}
+
+	fallbacks := []string{rules.Paths.FallbackTestsDir, filepath.Join(os.TempDir(), fallbackTestsDirName)}
+	for _, fallback := range fallbacks {
+		if fallback == "" {
+			continue
+		}
+		if err := checkWritableDir(fallback); err != nil {
+			log.Printf("Fallback tests directory %s is not writable: %v", fallback, err)
+			continue
+		}
+		fmt.Printf("⚠️  Tests directory %s is not writable: %v\n", rules.Paths.TestsDir, primaryErr)
+		fmt.Printf("⚠️  Writing generated tests to the fallback directory %s instead\n", fallback)
+		rules.Paths.TestsDir = fallback
+		return fallback, nil
+	}
+	return "", fmt.Errorf("tests directory %s is not writable and no fallback could be used: %v", rules.Paths.TestsDir, primaryErr)
 }
 
 // isHeaderFile checks if a file is a C++ header file
@@ -231,3 +378,30 @@ func copyFile(src, dst string) error {
 
 	return os.Chmod(dst, sourceInfo.Mode())
 }
+
+// SelectSourceFile displays source files and allows user to select one
+func SelectSourceFile(sourceFiles []string) (string, error) {
+	if len(sourceFiles) == 0 {
+		return "", fmt.Errorf("no C++ source files found")
+	}
+
+	fmt.Println("\n📋 Available C++ source files:")
+	for i, file := range sourceFiles {
+		fmt.Printf("%d. %s\n", i+1, file)
+	}
+
+	fmt.Print("\nSelect a source file (enter number): ")
+	scanner := bufio.NewScanner(os.Stdin)
+
+	if !scanner.Scan() {
+		return "", fmt.Errorf("failed to read input")
+	}
+
+	choice := strings.TrimSpace(scanner.Text())
+	index, err := strconv.Atoi(choice)
+	if err != nil || index < 1 || index > len(sourceFiles) {
+		return "", fmt.Errorf("invalid selection")
+	}
+
+	return sourceFiles[index-1], nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultFixturesTemplate is used when no fixtures template is configured.
+// {{framework_include}} is replaced with the test framework's main include.
+const defaultFixturesTemplate = `// Shared fixtures and helpers for the generated tests.
+// This file is rewritten from the fixtures template on every generation run.
+#pragma once
+
+{{framework_include}}
+`
+
+// writeSharedFixtures renders the fixtures template into TestsDir once per run and returns
+// its content, so generated tests can include it instead of duplicating common setup.
+// It returns "" when no shared fixtures header is configured.
+func (tg *TestGenerator) writeSharedFixtures() (string, error) {
+	tg.fixturesOnce.Do(func() {
+		if tg.rules.Fixtures.SharedHeader == "" {
+			return
+		}
+
+		template := defaultFixturesTemplate
+		if tg.rules.Fixtures.Template != "" {
+			data, err := os.ReadFile(tg.rules.Fixtures.Template)
+			if err != nil {
+				tg.fixturesErr = fmt.Errorf("failed to read fixtures template: %v", err)
+				return
+			}
+			template = string(data)
+		}
+
+		content := strings.ReplaceAll(template, "{{framework_include}}", tg.framework.MainInclude)
+		headerPath := filepath.Join(tg.rules.Paths.TestsDir, tg.rules.Fixtures.SharedHeader)
+		if err := tg.writeGeneratedFile(headerPath, content); err != nil {
+			tg.fixturesErr = fmt.Errorf("failed to write shared fixtures header: %v", err)
+			return
+		}
+		tg.recordSupportFile(headerPath)
+
+		log.Printf("Wrote shared fixtures header: %s", headerPath)
+		tg.fixtures = content
+	})
+
+	return tg.fixtures, tg.fixturesErr
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// frameworkProfile describes the conventions of a supported C++ test framework
+type frameworkProfile struct {
+	Name string
+
+	// MainInclude is the header every test file of this framework needs
+	MainInclude string
+
+	// NonFatalFamily and FatalFamily name the framework's non-fatal and fatal assertion macros
+	NonFatalFamily string
+	FatalFamily    string
+
+	// TestMacros open a test case in this framework
+	TestMacros []string
+
+	// Assertions maps abstract assertion kinds (equality, truthiness, ...) to the
+	// framework's non-fatal macro, FatalAssertions to the fatal one
+	Assertions      map[string]string
+	FatalAssertions map[string]string
+
+	// CompileFlags are passed to every test compile, LinkFlags go after the test's libraries.
+	// LinksGoogleTest adds the Google Test libraries found by FindGoogleTestLibraries.
+	CompileFlags    []string
+	LinkFlags       []string
+	LinksGoogleTest bool
+
+	// SkippedTest formats a test case that is reported as skipped, from a suite name and a message
+	SkippedTest string
+
+	// PropertyInclude and PropertyMacros are the RapidCheck integration of this framework,
+	// empty when it has none
+	PropertyInclude string
+	PropertyMacros  []string
+
+	// MatcherInclude and MatcherAssertions are the framework's matcher-based assertions,
+	// MatcherExample shows them to the model
+	MatcherInclude    string
+	MatcherAssertions []string
+	MatcherExample    string
+}
+
+var frameworkProfiles = map[string]frameworkProfile{
+	"gtest": {
+		Name:              "Google Test",
+		MainInclude:       "#include <gtest/gtest.h>",
+		NonFatalFamily:    "EXPECT_*",
+		FatalFamily:       "ASSERT_*",
+		TestMacros:        []string{"TEST", "TEST_F", "TEST_P"},
+		CompileFlags:      []string{"-pthread"},
+		LinksGoogleTest:   true,
+		SkippedTest:       "TEST(%s, NoTestsGenerated) {\n    GTEST_SKIP() << %q;\n}\n",
+		PropertyInclude:   "#include <rapidcheck/gtest.h>",
+		PropertyMacros:    []string{"RC_GTEST_PROP", "RC_GTEST_FIXTURE_PROP"},
+		MatcherInclude:    "#include <gmock/gmock.h>",
+		MatcherAssertions: []string{"EXPECT_THAT", "ASSERT_THAT"},
+		MatcherExample:    "EXPECT_THAT(value, Eq(expected)), EXPECT_THAT(name, HasSubstr(\"geo\")), EXPECT_THAT(items, ElementsAre(1, 2)), with using ::testing::Eq; and so on",
+		Assertions: map[string]string{
+			"equality":     "EXPECT_EQ",
+			"inequality":   "EXPECT_NE",
+			"truthiness":   "EXPECT_TRUE",
+			"falsiness":    "EXPECT_FALSE",
+			"less_than":    "EXPECT_LT",
+			"greater_than": "EXPECT_GT",
+			"near":         "EXPECT_NEAR",
+			"throws":       "EXPECT_THROW",
+			"no_throw":     "EXPECT_NO_THROW",
+		},
+		FatalAssertions: map[string]string{
+			"equality":     "ASSERT_EQ",
+			"inequality":   "ASSERT_NE",
+			"truthiness":   "ASSERT_TRUE",
+			"falsiness":    "ASSERT_FALSE",
+			"less_than":    "ASSERT_LT",
+			"greater_than": "ASSERT_GT",
+			"near":         "ASSERT_NEAR",
+			"throws":       "ASSERT_THROW",
+			"no_throw":     "ASSERT_NO_THROW",
+		},
+	},
+	"catch2": {
+		Name:              "Catch2",
+		MainInclude:       "#include <catch2/catch_test_macros.hpp>",
+		NonFatalFamily:    "CHECK*",
+		FatalFamily:       "REQUIRE*",
+		TestMacros:        []string{"TEST_CASE", "TEST_CASE_METHOD", "SCENARIO"},
+		LinkFlags:         []string{"-lCatch2Main", "-lCatch2"},
+		SkippedTest:       "TEST_CASE(\"%s\") {\n    SKIP(%q);\n}\n",
+		MatcherInclude:    "#include <catch2/matchers/catch_matchers_all.hpp>",
+		MatcherAssertions: []string{"CHECK_THAT", "REQUIRE_THAT"},
+		MatcherExample:    "CHECK_THAT(name, Catch::Matchers::ContainsSubstring(\"geo\")), CHECK_THAT(value, Catch::Matchers::WithinRel(expected))",
+		Assertions: map[string]string{
+			"equality":     "CHECK(actual == expected)",
+			"inequality":   "CHECK(actual != expected)",
+			"truthiness":   "CHECK(condition)",
+			"falsiness":    "CHECK_FALSE(condition)",
+			"less_than":    "CHECK(a < b)",
+			"greater_than": "CHECK(a > b)",
+			"near":         "CHECK(actual == Approx(expected))",
+			"throws":       "CHECK_THROWS_AS(expression, exception)",
+			"no_throw":     "CHECK_NOTHROW(expression)",
+		},
+		FatalAssertions: map[string]string{
+			"equality":     "REQUIRE(actual == expected)",
+			"inequality":   "REQUIRE(actual != expected)",
+			"truthiness":   "REQUIRE(condition)",
+			"falsiness":    "REQUIRE_FALSE(condition)",
+			"less_than":    "REQUIRE(a < b)",
+			"greater_than": "REQUIRE(a > b)",
+			"near":         "REQUIRE(actual == Approx(expected))",
+			"throws":       "REQUIRE_THROWS_AS(expression, exception)",
+			"no_throw":     "REQUIRE_NOTHROW(expression)",
+		},
+	},
+}
+
+// getFrameworkProfile returns the profile for the configured test framework, defaulting to gtest
+func getFrameworkProfile(testFramework string) frameworkProfile {
+	name := strings.ToLower(strings.TrimSpace(testFramework))
+	switch name {
+	case "googletest", "google test", "gtest":
+		name = "gtest"
+	case "catch", "catch2":
+		name = "catch2"
+	}
+
+	if profile, ok := frameworkProfiles[name]; ok {
+		return profile
+	}
+	return frameworkProfiles["gtest"]
+}
+
+// frameworkCompileFlags returns the framework's compile flags, or Build.FrameworkCompileFlags when set
+func frameworkCompileFlags(rules *Rules) []string {
+	if len(rules.Build.FrameworkCompileFlags) > 0 {
+		return rules.Build.FrameworkCompileFlags
+	}
+	return getFrameworkProfile(rules.TestFramework).CompileFlags
+}
+
+// frameworkLinkArgs returns the libraries and flags every test of the framework is linked with.
+// Build.FrameworkLinkFlags replaces the framework's own link flags when set.
+func frameworkLinkArgs(rules *Rules) ([]string, error) {
+	profile := getFrameworkProfile(rules.TestFramework)
+
+	var args []string
+	if profile.LinksGoogleTest {
+		gtestLib, gtestMainLib, err := FindGoogleTestLibraries(rules)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find Google Test libraries: %v", err)
+		}
+		// gMock matchers live in libgmock, which has to come before the gtest libraries
+		if rules.Assertions.UseMatchers {
+			if gmockLib := findGMockLibrary(gtestLib); gmockLib != "" {
+				args = append(args, gmockLib)
+			} else {
+				log.Printf("libgmock not found next to %s, matcher tests may fail to link", gtestLib)
+			}
+		}
+		args = append(args, gtestLib, gtestMainLib)
+	}
+
+	return append(args, frameworkExtraLinkFlags(rules)...), nil
+}
+
+// frameworkExtraLinkFlags returns the framework's link flags besides the Google Test
+// libraries: Build.FrameworkLinkFlags when set, the profile's otherwise
+func frameworkExtraLinkFlags(rules *Rules) []string {
+	if len(rules.Build.FrameworkLinkFlags) > 0 {
+		return rules.Build.FrameworkLinkFlags
+	}
+	return getFrameworkProfile(rules.TestFramework).LinkFlags
+}
+
+// findGMockLibrary returns the gMock library built alongside a Google Test library, looked up
+// in its directory and, for googlemock/gtest build trees, the one above; "" if there is none
+func findGMockLibrary(gtestLib string) string {
+	ext := filepath.Ext(gtestLib)
+	dir := filepath.Dir(gtestLib)
+	for _, candidateDir := range []string{dir, filepath.Dir(dir)} {
+		candidate := filepath.Join(candidateDir, "libgmock"+ext)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// testCasePattern matches a call of any of the profile's test macros, such as "TEST_F("
+func (fp frameworkProfile) testCasePattern() *regexp.Regexp {
+	quoted := make([]string, len(fp.TestMacros))
+	for i, macro := range fp.TestMacros {
+		quoted[i] = regexp.QuoteMeta(macro)
+	}
+	return regexp.MustCompile(`\b(?:` + strings.Join(quoted, "|") + `)\s*\(`)
+}
+
+// withTestMacros returns the profile with additional macros counted as test cases
+func (fp frameworkProfile) withTestMacros(macros ...string) frameworkProfile {
+	fp.TestMacros = append(append([]string{}, fp.TestMacros...), macros...)
+	return fp
+}
+
+// translateAssertion maps an assertion preference to this framework's macro. Preferences may be
+// abstract kinds ("equality") or gtest macros ("EXPECT_EQ"); unknown values are passed through.
+func (fp frameworkProfile) translateAssertion(preference string) string {
+	kind := strings.ToLower(strings.TrimSpace(preference))
+	if macro, ok := fp.Assertions[kind]; ok {
+		return macro
+	}
+
+	// Existing configs list gtest macros, map them back to their abstract kind
+	gtest := frameworkProfiles["gtest"]
+	for kind, macro := range gtest.Assertions {
+		if macro == preference {
+			return fp.Assertions[kind]
+		}
+	}
+	for kind, macro := range gtest.FatalAssertions {
+		if macro == preference {
+			return fp.FatalAssertions[kind]
+		}
+	}
+
+	return preference
+}
+
+// translateAssertions maps a list of assertion preferences to this framework's macros, without duplicates
+func (fp frameworkProfile) translateAssertions(preferences []string) []string {
+	var macros []string
+	seen := make(map[string]bool)
+
+	for _, preference := range preferences {
+		macro := fp.translateAssertion(preference)
+		if macro != "" && !seen[macro] {
+			seen[macro] = true
+			macros = append(macros, macro)
+		}
+	}
+
+	return macros
+}
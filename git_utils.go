@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Matches a unified diff hunk header and captures the new-file start line and length
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// lineRange is an inclusive range of line numbers
+type lineRange struct {
+	Start int
+	End   int
+}
+
+// overlaps reports whether the range intersects the lines start..end
+func (lr lineRange) overlaps(start, end int) bool {
+	return lr.Start <= end && start <= lr.End
+}
+
+// ChangedLineRanges returns the line ranges of file that changed since ref, including
+// uncommitted changes. Files git doesn't track yet are reported as entirely changed.
+func ChangedLineRanges(ref string, file string) ([]lineRange, error) {
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for %s: %v", file, err)
+	}
+	dir, name := filepath.Dir(absFile), filepath.Base(absFile)
+
+	trackedCmd := exec.Command("git", "ls-files", "--error-unmatch", name)
+	trackedCmd.Dir = dir
+	if err := trackedCmd.Run(); err != nil {
+		return []lineRange{{Start: 1, End: math.MaxInt}}, nil
+	}
+
+	diffCmd := exec.Command("git", "diff", "--unified=0", "--no-color", ref, "--", name)
+	diffCmd.Dir = dir
+	output, err := diffCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff against %s failed for %s: %v", ref, file, err)
+	}
+
+	var ranges []lineRange
+	for _, line := range strings.Split(string(output), "\n") {
+		match := hunkHeaderPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		start, _ := strconv.Atoi(match[1])
+		length := 1
+		if match[2] != "" {
+			length, _ = strconv.Atoi(match[2])
+		}
+
+		// Pure deletions have no new lines; attribute them to the line they were removed at
+		end := start + length - 1
+		if length == 0 {
+			end = start
+		}
+		ranges = append(ranges, lineRange{Start: start, End: end})
+	}
+
+	return ranges, nil
+}
+
+// Defaults for committing generated tests to a new branch
+const (
+	defaultGitBranchTemplate  = "generated-tests/{{timestamp}}"
+	defaultGitMessageTemplate = "Add {{count}} generated unit test files\n\nGenerated with {{model}}."
+)
+
+// expandGitTemplate fills the {{date}}, {{timestamp}}, {{model}} and {{count}} placeholders
+func expandGitTemplate(template string, now time.Time, model string, count int) string {
+	replacer := strings.NewReplacer(
+		"{{date}}", now.Format("2006-01-02"),
+		"{{timestamp}}", now.Format("20060102-150405"),
+		"{{model}}", model,
+		"{{count}}", strconv.Itoa(count),
+	)
+	return replacer.Replace(template)
+}
+
+// runGit runs a git command in dir and returns its trimmed output
+func runGit(dir string, args ...string) (string, error) {
+	return runGitWithEnv(dir, nil, args...)
+}
+
+// runGitWithEnv runs a git command in dir with extra environment variables
+func runGitWithEnv(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %v\nOutput: %s", args[0], err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// CommitGeneratedTests creates a new branch in the repository containing dir holding one
+// commit on top of HEAD with the given files. The commit is built in a temporary index, so the
+// checked out branch, the working tree and anything the user had staged stay as they are. It
+// returns "" without doing anything when dir is not inside a git repository, otherwise the
+// name of the created branch.
+func CommitGeneratedTests(dir string, files []string, branch, message string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for %s: %v", dir, err)
+	}
+	repoRoot, err := runGit(absDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		log.Printf("Not committing generated tests: %s is not inside a git repository", dir)
+		return "", nil
+	}
+
+	// Branch names can't contain spaces, the model name may well have them
+	branch = strings.Join(strings.Fields(branch), "-")
+	if _, err := runGit(repoRoot, "check-ref-format", "--branch", branch); err != nil {
+		return "", fmt.Errorf("invalid branch name %q", branch)
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		absFile, err := filepath.Abs(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to get absolute path for %s: %v", file, err)
+		}
+		paths = append(paths, absFile)
+	}
+
+	indexDir, err := os.MkdirTemp("", "utg-index-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary index directory: %v", err)
+	}
+	defer os.RemoveAll(indexDir)
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(indexDir, "index")}
+
+	// Start from HEAD's tree; a repository without commits starts from an empty one
+	parent, err := runGit(repoRoot, "rev-parse", "--verify", "--quiet", "HEAD")
+	if err == nil {
+		if _, err := runGitWithEnv(repoRoot, env, "read-tree", parent); err != nil {
+			return "", err
+		}
+	}
+	if _, err := runGitWithEnv(repoRoot, env, append([]string{"add", "--"}, paths...)...); err != nil {
+		return "", err
+	}
+	tree, err := runGitWithEnv(repoRoot, env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+
+	commitArgs := []string{"commit-tree", tree, "-m", message}
+	if parent != "" {
+		commitArgs = append(commitArgs, "-p", parent)
+	}
+	commit, err := runGit(repoRoot, commitArgs...)
+	if err != nil {
+		return "", err
+	}
+	// The empty old value makes update-ref fail instead of moving an existing branch
+	if _, err := runGit(repoRoot, "update-ref", "refs/heads/"+branch, commit, ""); err != nil {
+		return "", err
+	}
+
+	return branch, nil
+}
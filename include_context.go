@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// includedContextHeaders follows the quoted #includes of a group's files up to
+// LLMPromptGuidance.IncludeDepth levels and returns the project headers they reach, in the order
+// found. Every header is visited once, so circular includes end where they loop back; headers
+// beyond the depth limit are left out and reported.
+func (tg *TestGenerator) includedContextHeaders(filename string, files []groupFile) []groupFile {
+	maxDepth := tg.rules.LLMPromptGuidance.IncludeDepth
+	if maxDepth <= 0 {
+		return nil
+	}
+
+	absCodebase, err := filepath.Abs(tg.rules.Paths.CodebaseDir)
+	if err != nil {
+		return nil
+	}
+
+	visited := make(map[string]bool)
+	var level []groupFile
+	for _, file := range files {
+		if absPath, err := filepath.Abs(file.Name); err == nil {
+			visited[absPath] = true
+		}
+		level = append(level, file)
+	}
+
+	var headers []groupFile
+	truncated := 0
+	for depth := 1; len(level) > 0; depth++ {
+		var next []groupFile
+		for _, file := range level {
+			for _, header := range tg.resolveQuotedIncludes(file) {
+				if visited[header] {
+					continue
+				}
+				visited[header] = true
+				if depth > maxDepth {
+					truncated++
+					continue
+				}
+
+				content, err := os.ReadFile(header)
+				if err != nil {
+					continue
+				}
+				// Named like the files read from the codebase
+				name := header
+				if rel, err := filepath.Rel(absCodebase, header); err == nil {
+					name = filepath.Join(tg.rules.Paths.CodebaseDir, rel)
+				}
+				included := groupFile{Name: name, Content: string(content)}
+				headers = append(headers, included)
+				next = append(next, included)
+			}
+		}
+		level = next
+	}
+
+	if truncated > 0 {
+		fmt.Printf("ℹ️  %s: include depth %d reached, %d more headers left out of the context\n", filename, maxDepth, truncated)
+	}
+	return headers
+}
+
+// resolveQuotedIncludes returns the absolute paths of the project headers a file includes with
+// quotes, looked up next to the file and then from the codebase root. System headers and
+// includes that resolve outside the codebase are skipped.
+func (tg *TestGenerator) resolveQuotedIncludes(file groupFile) []string {
+	absCodebase, err := filepath.Abs(tg.rules.Paths.CodebaseDir)
+	if err != nil {
+		return nil
+	}
+
+	var resolved []string
+	for _, include := range tg.sourceInfo(file.Name, file.Content).Includes {
+		if !strings.HasPrefix(include, `"`) {
+			continue
+		}
+		name := strings.Trim(include, `"`)
+
+		for _, dir := range []string{filepath.Dir(file.Name), absCodebase} {
+			candidate, err := filepath.Abs(filepath.Join(dir, name))
+			if err != nil {
+				continue
+			}
+			if rel, err := filepath.Rel(absCodebase, candidate); err != nil || strings.HasPrefix(rel, "..") {
+				continue
+			}
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				resolved = append(resolved, candidate)
+				break
+			}
+		}
+	}
+	return resolved
+}
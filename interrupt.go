@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// abortWindow is how quickly a second Ctrl-C has to follow the first one to abort the whole run
+const abortWindow = 2 * time.Second
+
+// interruptHandler turns Ctrl-C into "skip the files in flight" and a quick second Ctrl-C into
+// "abort the run"
+type interruptHandler struct {
+	signals chan os.Signal
+	abort   context.CancelFunc
+
+	mu            sync.Mutex
+	inFlight      map[int]context.CancelFunc
+	nextID        int
+	lastInterrupt time.Time
+}
+
+// newInterruptHandler starts listening for Ctrl-C; abort is called when the run should stop
+func newInterruptHandler(abort context.CancelFunc) *interruptHandler {
+	h := &interruptHandler{
+		signals:  make(chan os.Signal, 1),
+		abort:    abort,
+		inFlight: make(map[int]context.CancelFunc),
+	}
+	signal.Notify(h.signals, os.Interrupt)
+	go h.listen()
+	return h
+}
+
+// listen handles incoming signals until stop is called
+func (h *interruptHandler) listen() {
+	for range h.signals {
+		h.mu.Lock()
+		now := time.Now()
+		abort := !h.lastInterrupt.IsZero() && now.Sub(h.lastInterrupt) < abortWindow
+		h.lastInterrupt = now
+		for id, cancel := range h.inFlight {
+			cancel()
+			delete(h.inFlight, id)
+		}
+		h.mu.Unlock()
+
+		if abort {
+			fmt.Println("\n🛑 Aborting the run...")
+			h.abort()
+			continue
+		}
+		fmt.Println("\n⏭️  Skipping current file(s), press Ctrl-C again to abort the run")
+	}
+}
+
+// fileContext returns a context for one file that the next Ctrl-C cancels; done must be
+// called once the file is finished
+func (h *interruptHandler) fileContext(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+
+	h.mu.Lock()
+	id := h.nextID
+	h.nextID++
+	h.inFlight[id] = cancel
+	h.mu.Unlock()
+
+	return ctx, func() {
+		h.mu.Lock()
+		delete(h.inFlight, id)
+		h.mu.Unlock()
+		cancel()
+	}
+}
+
+// stop restores the default Ctrl-C behaviour
+func (h *interruptHandler) stop() {
+	signal.Stop(h.signals)
+	close(h.signals)
+}
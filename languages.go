@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+var (
+	cppProfile = LanguageProfile{Language: "C++", Compiler: "g++", Standard: "c++17"}
+	cProfile   = LanguageProfile{Language: "C", Compiler: "gcc", Standard: "c11"}
+
+	// builtinLanguageProfiles maps implementation file extensions to their default profile
+	builtinLanguageProfiles = map[string]LanguageProfile{
+		".cpp": cppProfile,
+		".cc":  cppProfile,
+		".cxx": cppProfile,
+		".c":   cProfile,
+	}
+)
+
+// languageProfileFor returns the profile of an implementation file, preferring the profiles
+// configured in rules.yaml over the built-in ones. It reports false for other files.
+func languageProfileFor(filename string, rules *Rules) (LanguageProfile, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	profile, ok := builtinLanguageProfiles[ext]
+	if configured, found := rules.LanguageProfiles[ext]; found {
+		// Unset fields keep their built-in value, or the C++ one for new extensions
+		if !ok {
+			profile = cppProfile
+		}
+		if configured.Language != "" {
+			profile.Language = configured.Language
+		}
+		if configured.Compiler != "" {
+			profile.Compiler = configured.Compiler
+		}
+		if configured.Standard != "" {
+			profile.Standard = configured.Standard
+		}
+		ok = true
+	}
+	return profile, ok
+}
+
+// isImplementationFile reports whether a file is a source file of a known language
+func isImplementationFile(filename string, rules *Rules) bool {
+	_, ok := languageProfileFor(filename, rules)
+	return ok
+}
+
+// compiledAsCpp reports whether a profile's sources can be passed straight to the C++ compiler
+func (p LanguageProfile) compiledAsCpp() bool {
+	return p.Compiler == "" || p.Compiler == "g++" || p.Compiler == "c++"
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -10,21 +11,71 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/ollama/ollama/api"
+	"gopkg.in/yaml.v3"
 )
 
 type App struct {
 	client *api.Client
 	rules  *Rules
 	debug  bool
+	flags  cliFlags
+
+	// failed is set when a strict run didn't produce every test file, to fail the exit code
+	failed bool
+}
+
+// cliFlags holds command-line overrides applied on top of rules.yaml
+type cliFlags struct {
+	seed           int
+	since          string
+	focus          string
+	focusReplace   bool
+	match          string
+	repeat         int
+	benchmark      int
+	modifiedWithin time.Duration
+	strict         bool
+	noCoverage     bool
+	printConfig    bool
+	lastRun        bool
+	force          bool
+	maxTotalTests  int
+	rulesPath      string
+	resetCoverage  bool
+}
+
+func parseFlags() cliFlags {
+	var flags cliFlags
+	flag.IntVar(&flags.seed, "seed", -1, "Seed passed to the model for reproducible generation (negative leaves it unset)")
+	flag.StringVar(&flags.since, "since", "", "Only generate tests for functions changed since this git ref")
+	flag.StringVar(&flags.focus, "focus", "", "Comma-separated methods to test in this run, merged into methods_to_test")
+	flag.StringVar(&flags.match, "match", "", "Only generate tests for files whose path relative to codebase_dir matches this glob (** spans directories)")
+	flag.IntVar(&flags.repeat, "repeat", 0, "Run each test executable this many times and report flaky tests")
+	flag.IntVar(&flags.benchmark, "benchmark", 0, "Generate tests for this many files without saving them, report model throughput and exit")
+	flag.DurationVar(&flags.modifiedWithin, "modified-within", 0, "Only generate tests for files modified within this duration, e.g. 24h")
+	flag.BoolVar(&flags.strict, "strict", false, "Exit with a non-zero status when any group doesn't produce a valid test file")
+	flag.BoolVar(&flags.noCoverage, "no-coverage", false, "Compile and run tests without coverage instrumentation or reports, for quick pass/fail checks")
+	flag.BoolVar(&flags.printConfig, "print-config", false, "Print the effective configuration, after defaults and command-line overrides, as YAML and exit")
+	flag.BoolVar(&flags.lastRun, "last-run", false, "Print the summary of the last generation and test runs from the run log and exit")
+	flag.BoolVar(&flags.force, "force", false, "Overwrite files in tests_dir that weren't generated by utg")
+	flag.IntVar(&flags.maxTotalTests, "max-total-tests", 0, "Stop generating new test files once this many test cases have been written in the run (0 = no limit)")
+	flag.StringVar(&flags.rulesPath, "rules", "rules.yaml", "Rules file, or an http(s) URL to fetch them from (UTG_RULES_TOKEN is sent as a bearer token)")
+	flag.BoolVar(&flags.resetCoverage, "reset-coverage", false, "Clear the coverage accumulated by earlier runs (coverage.accumulate) before starting")
+	flag.BoolVar(&flags.focusReplace, "focus-replace", false, "Replace methods_to_test with the -focus methods instead of merging")
+	flag.Parse()
+	return flags
 }
 
 func main() {
 	app := &App{
 		debug: os.Getenv("DEBUG") == "true",
+		flags: parseFlags(),
 	}
 
 	// Configure logging based on debug mode
@@ -34,24 +85,75 @@ func main() {
 		log.SetFlags(log.LstdFlags | log.Lshortfile)
 	}
 
+	app.loadRules()
+
+	if app.flags.printConfig {
+		if err := app.printConfig(); err != nil {
+			app.printError("Failed to print configuration: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if app.flags.lastRun {
+		if err := PrintLastRun(app.rules); err != nil {
+			app.printError("Failed to show the last run: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if app.flags.resetCoverage {
+		if err := ResetCoverageBaseline(app.rules); err != nil {
+			app.printError("Failed to reset coverage: %v", err)
+			os.Exit(1)
+		}
+	}
+
 	if err := app.initialize(); err != nil {
 		app.printError("Initialization failed: %v", err)
 		os.Exit(1)
 	}
 
+	if app.flags.benchmark > 0 {
+		app.runBenchmark(app.flags.benchmark)
+		return
+	}
+
 	app.runCLI()
-}
 
-func (app *App) initialize() error {
-	app.printInfo("🔧 Initializing application...")
+	if app.failed {
+		os.Exit(1)
+	}
+}
 
-	// Load rules
-	rules, err := LoadRules("rules.yaml")
+// loadRules loads the rules file or URL, falling back to the defaults, and applies the command-line overrides
+func (app *App) loadRules() {
+	rules, err := LoadRules(app.flags.rulesPath)
 	if err != nil {
-		app.printWarning("Failed to load rules.yaml, using defaults: %v", err)
+		app.printWarning("Failed to load %s, using defaults: %v", redactURL(app.flags.rulesPath), err)
 		rules = GetDefaultRules()
 	}
 	app.rules = rules
+	app.applyFlagOverrides()
+}
+
+// printConfig prints the effective configuration, after defaults and overrides, as YAML
+func (app *App) printConfig() error {
+	// The Ollama host comes from the environment rather than rules.yaml
+	fmt.Printf("# ollama host: %s\n", ollamaHost())
+
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(app.rules); err != nil {
+		return fmt.Errorf("failed to encode rules: %v", err)
+	}
+	return encoder.Close()
+}
+
+func (app *App) initialize() error {
+	app.printInfo("🔧 Initializing application...")
+	rules := app.rules
 
 	if app.debug {
 		app.printDebug("Using rules: Language=%s, Framework=%s, Model=%s",
@@ -59,7 +161,7 @@ func (app *App) initialize() error {
 	}
 
 	// Load extra prompt if available
-	_, err = LoadExtraPrompt("extra_prompt.txt")
+	_, err := LoadExtraPrompt("extra_prompt.txt")
 	if err != nil && app.debug {
 		app.printDebug("Failed to load extra_prompt.txt: %v", err)
 	}
@@ -85,6 +187,73 @@ func (app *App) initialize() error {
 	return nil
 }
 
+// applyFlagOverrides applies command-line flags on top of the loaded rules
+func (app *App) applyFlagOverrides() {
+	if app.flags.seed >= 0 {
+		seed := app.flags.seed
+		app.rules.ModelConfig.Options.Seed = &seed
+	}
+
+	if app.flags.repeat > 0 {
+		app.rules.Build.Repeat = app.flags.repeat
+	}
+
+	if app.flags.noCoverage {
+		app.rules.Build.NoCoverage = true
+	}
+
+	if focus := splitList(app.flags.focus); len(focus) > 0 {
+		methods := app.rules.MethodsToTest
+		// An automatic method list has nothing to merge into, so focus replaces it
+		if app.flags.focusReplace || methods.Source != "manual" {
+			methods.ManualList = focus
+		} else {
+			methods.ManualList = mergeUnique(methods.ManualList, focus)
+		}
+		methods.Source = "manual"
+		app.rules.MethodsToTest = methods
+		app.printInfo("Focusing on methods: %s", strings.Join(methods.ManualList, ", "))
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// mergeUnique appends the items of extra missing from base
+func mergeUnique(base, extra []string) []string {
+	merged := append([]string{}, base...)
+	seen := make(map[string]bool)
+	for _, item := range base {
+		seen[item] = true
+	}
+	for _, item := range extra {
+		if !seen[item] {
+			seen[item] = true
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
+
+// generationOptions builds the per-run generation options from the command-line flags
+func (app *App) generationOptions() GenerationOptions {
+	return GenerationOptions{
+		SinceRef:      app.flags.since,
+		Debug:         app.debug,
+		Strict:        app.flags.strict,
+		Force:         app.flags.force,
+		MaxTotalTests: app.flags.maxTotalTests,
+	}
+}
+
 func (app *App) runCLI() {
 	scanner := bufio.NewScanner(os.Stdin)
 
@@ -104,6 +273,8 @@ func (app *App) runCLI() {
 			app.runTests()
 		case "3":
 			app.runBuild()
+		case "4":
+			app.regenerateSingleTest()
 		case "0", "exit", "quit":
 			app.printInfo("👋 Goodbye!")
 			return
@@ -120,23 +291,45 @@ func (app *App) printMenu() {
 	fmt.Println("[1] 🏗️  Generate C++ Tests")
 	fmt.Println("[2] 🏃 Run Tests")
 	fmt.Println("[3] 🔨 Build C++ Project")
+	fmt.Println("[4] 🔁 Regenerate Single Test")
 	fmt.Println("[0] 🚪 Exit")
 	fmt.Print("Enter your choice: ")
 }
 
+// readCodebase reads the files tests are generated from. When testing prebuilt libraries
+// only their public headers are read. Line endings are normalized to LF for the prompt
+// unless line_endings is "preserve".
+func (app *App) readCodebase() (map[string]string, error) {
+	files, err := ReadCodebase(app.rules.ScannedDir(), app.rules.Paths.FoldersToScan, app.rules.Paths.FollowSymlinks, app.rules.LanguageProfiles, app.rules.Paths.ReadWorkers)
+	if err != nil {
+		return nil, err
+	}
+
+	preserve := lineEndingStyle(app.rules.OutputFormat.LineEndings) == lineEndingsPreserve
+	for filename, content := range files {
+		if app.rules.UsesPrebuiltLibraries() && !isHeaderFile(filename) {
+			delete(files, filename)
+		} else if !preserve {
+			files[filename] = normalizeLineEndings(content, lineEndingsLF)
+		}
+	}
+	return files, nil
+}
+
 func (app *App) generateTests() {
 	app.printInfo("🏗️ Starting test generation...")
 
 	// Read codebase
-	files, err := ReadCodebase(app.rules.Paths.CodebaseDir, app.rules.Paths.FoldersToScan)
+	files, err := app.readCodebase()
 	if err != nil {
 		app.printError("Failed to read codebase: %v", err)
 		return
 	}
 
-	// Create tests directory if it doesn't exist
-	if err := os.MkdirAll(app.rules.Paths.TestsDir, 0755); err != nil {
-		app.printError("Failed to create tests directory: %v", err)
+	// Create tests directory if it doesn't exist, or switch to the fallback
+	configuredTestsDir := app.rules.Paths.TestsDir
+	if _, err := ResolveTestsDir(app.rules); err != nil {
+		app.printError("Failed to prepare tests directory: %v", err)
 		return
 	}
 
@@ -144,18 +337,302 @@ func (app *App) generateTests() {
 		app.printDebug("Tests directory ready: %s", app.rules.Paths.TestsDir)
 	}
 
-	// Generate unit tests
+	// Context headers never get tests of their own, so they are set aside before filtering
 	generator := NewTestGenerator(app.client, app.rules)
+	generator.options = app.generationOptions()
+	files = generator.SplitContextHeaders(files)
+
+	if app.flags.match != "" {
+		files = app.filterMatchingFiles(files, app.flags.match)
+		if len(files) == 0 {
+			app.printWarning("No files match %s", app.flags.match)
+			return
+		}
+	}
+
+	if app.flags.modifiedWithin > 0 {
+		files = app.filterRecentlyModifiedFiles(files, app.flags.modifiedWithin)
+		if len(files) == 0 {
+			app.printWarning("No files were modified within %v", app.flags.modifiedWithin)
+			return
+		}
+	}
+
+	if app.rules.Coverage.SkipCovered {
+		files = app.skipCoveredFiles(files)
+	}
+
+	// Generate unit tests
 	startTime := time.Now()
 	err = generator.ProcessFiles(files)
 	duration := time.Since(startTime)
 
+	// Commit whatever was written, even when some groups failed
+	if app.rules.Output.GitCommit.Enabled {
+		app.commitGeneratedTests(generator.WrittenFiles(), generator.SupportFiles())
+	}
+
 	if err != nil {
 		app.printError("Failed to process files: %v", err)
+		if app.flags.strict {
+			app.failed = true
+		}
 		return
 	}
 
 	app.printSuccess("Test generation completed successfully in %v", duration)
+	if app.rules.Paths.TestsDir != configuredTestsDir {
+		app.printWarning("Tests were written to the fallback directory %s, not %s", app.rules.Paths.TestsDir, configuredTestsDir)
+	}
+}
+
+// commitGeneratedTests commits the written test files and their support files to a new
+// branch named from the configured template, so they can be reviewed as one changeset
+func (app *App) commitGeneratedTests(files, supportFiles []string) {
+	if len(files) == 0 {
+		app.printWarning("No test files were written, nothing to commit")
+		return
+	}
+
+	config := app.rules.Output.GitCommit
+	branchTemplate := config.Branch
+	if branchTemplate == "" {
+		branchTemplate = defaultGitBranchTemplate
+	}
+	messageTemplate := config.Message
+	if messageTemplate == "" {
+		messageTemplate = defaultGitMessageTemplate
+	}
+
+	now := time.Now()
+	model := app.rules.ModelConfig.PrimaryModel
+	branch := expandGitTemplate(branchTemplate, now, model, len(files))
+	message := expandGitTemplate(messageTemplate, now, model, len(files))
+
+	// Support files like the Makefile go into the commit but don't count towards {{count}}
+	created, err := CommitGeneratedTests(app.rules.Paths.TestsDir, append(append([]string{}, files...), supportFiles...), branch, message)
+	switch {
+	case err != nil:
+		app.printError("Failed to commit generated tests: %v", err)
+	case created == "":
+		app.printWarning("Tests directory is not in a git repository, skipping commit")
+	default:
+		app.printSuccess("Committed %d test files to branch %s", len(files), created)
+	}
+}
+
+// runBenchmark generates tests for a sample of the codebase without saving them and reports
+// the model's throughput along with the projected time for generating the whole codebase
+func (app *App) runBenchmark(sampleSize int) {
+	app.printInfo("⏱️ Benchmarking model %s on %d files...", app.rules.ModelConfig.PrimaryModel, sampleSize)
+
+	files, err := app.readCodebase()
+	if err != nil {
+		app.printError("Failed to read codebase: %v", err)
+		return
+	}
+
+	generator := NewTestGenerator(app.client, app.rules)
+	generator.options = app.generationOptions()
+	generator.options.DryRun = true
+
+	groups := GroupFiles(generator.SplitContextHeaders(files))
+	var targets []string
+	for baseName, group := range groups {
+		if TestTargetFile(group, app.rules) != "" {
+			targets = append(targets, baseName)
+		}
+	}
+	if len(targets) == 0 {
+		app.printWarning("No source files found to benchmark")
+		return
+	}
+	sort.Strings(targets)
+
+	sample := make(map[string]string)
+	for _, baseName := range targets[:min(sampleSize, len(targets))] {
+		for filename, content := range groups[baseName] {
+			sample[filename] = content
+		}
+	}
+
+	startTime := time.Now()
+	if err := generator.ProcessFiles(sample); err != nil {
+		app.printWarning("Some sample files failed: %v", err)
+	}
+	elapsed := time.Since(startTime)
+
+	total, sampled := generator.totalMetrics()
+	if sampled == 0 {
+		app.printError("No tests were generated, nothing to measure")
+		return
+	}
+
+	perFile := elapsed / time.Duration(sampled)
+	fmt.Println("\n⏱️ Benchmark results:")
+	fmt.Printf("  Files sampled: %d\n", sampled)
+	if seconds := total.TotalDuration.Seconds(); seconds > 0 {
+		fmt.Printf("  Throughput: %.1f tokens/sec\n", float64(total.EvalCount)/seconds)
+	}
+	fmt.Printf("  Average time per file: %v\n", perFile.Round(time.Millisecond))
+	fmt.Printf("  Projected time for all %d files: %v\n", len(targets), (perFile * time.Duration(len(targets))).Round(time.Second))
+}
+
+// filterMatchingFiles keeps the groups with at least one file matching the glob, so a
+// matching source keeps its header as context
+func (app *App) filterMatchingFiles(files map[string]string, pattern string) map[string]string {
+	filtered := make(map[string]string)
+	for _, group := range GroupFiles(files) {
+		matched := false
+		for filename := range group {
+			relPath, err := filepath.Rel(app.rules.Paths.CodebaseDir, filename)
+			if err != nil {
+				relPath = filename
+			}
+			if matchGlob(pattern, filepath.ToSlash(relPath)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		for filename, content := range group {
+			filtered[filename] = content
+		}
+	}
+
+	app.printInfo("Matched %d of %d files against %s", len(filtered), len(files), pattern)
+	return filtered
+}
+
+// filterRecentlyModifiedFiles keeps the groups with at least one file modified within the
+// window, so a changed header also refreshes the tests of its source
+func (app *App) filterRecentlyModifiedFiles(files map[string]string, window time.Duration) map[string]string {
+	cutoff := time.Now().Add(-window)
+	filtered := make(map[string]string)
+	for _, group := range GroupFiles(files) {
+		modified := false
+		for filename := range group {
+			info, err := os.Stat(filename)
+			if err != nil {
+				log.Printf("Failed to stat %s: %v", filename, err)
+				continue
+			}
+			if info.ModTime().After(cutoff) {
+				modified = true
+				break
+			}
+		}
+		if !modified {
+			continue
+		}
+		for filename, content := range group {
+			filtered[filename] = content
+		}
+	}
+
+	app.printInfo("%d of %d files belong to groups modified within %v", len(filtered), len(files), window)
+	return filtered
+}
+
+// skipCoveredFiles drops groups whose implementation is already covered by existing tests
+func (app *App) skipCoveredFiles(files map[string]string) map[string]string {
+	app.printInfo("📊 Measuring coverage of existing tests...")
+
+	coverage, err := MeasureExistingCoverage(app.rules.TestSearchDirs(), app.rules.Paths.CodebaseDir, app.rules)
+	if err != nil {
+		app.printWarning("Coverage pre-pass failed, generating tests for all files: %v", err)
+		return files
+	}
+
+	remaining := make(map[string]string)
+	var skipped []string
+
+	for _, group := range GroupFiles(files) {
+		implFile := TestTargetFile(group, app.rules)
+		absImplFile, _ := filepath.Abs(implFile)
+
+		if fileCoverage, ok := coverage[absImplFile]; ok && implFile != "" &&
+			fileCoverage.Percentage() >= app.rules.Coverage.MinimumThreshold {
+			skipped = append(skipped, fmt.Sprintf("%s (%.2f%%)", implFile, fileCoverage.Percentage()))
+			continue
+		}
+
+		for filename, content := range group {
+			remaining[filename] = content
+		}
+	}
+
+	if len(skipped) > 0 {
+		sort.Strings(skipped)
+		app.printInfo("Skipping %d files already at or above %.2f%% coverage:", len(skipped), app.rules.Coverage.MinimumThreshold)
+		for _, file := range skipped {
+			fmt.Printf("   - %s\n", file)
+		}
+	}
+
+	return remaining
+}
+
+func (app *App) regenerateSingleTest() {
+	app.printInfo("🔁 Regenerating a single test...")
+
+	// Read codebase
+	files, err := app.readCodebase()
+	if err != nil {
+		app.printError("Failed to read codebase: %v", err)
+		return
+	}
+
+	generator := NewTestGenerator(app.client, app.rules)
+	generator.options = app.generationOptions()
+
+	// Only groups with an implementation file produce a test
+	fileGroups := GroupFiles(generator.SplitContextHeaders(files))
+	groupsByFile := make(map[string]map[string]string)
+	var sourceFiles []string
+	for _, group := range fileGroups {
+		if implFile := TestTargetFile(group, app.rules); implFile != "" {
+			groupsByFile[implFile] = group
+			sourceFiles = append(sourceFiles, implFile)
+		}
+	}
+	sort.Strings(sourceFiles)
+
+	selectedFile, err := SelectSourceFile(sourceFiles)
+	if err != nil {
+		app.printError("Failed to select source file: %v", err)
+		return
+	}
+
+	// Create tests directory if it doesn't exist, or switch to the fallback
+	if _, err := ResolveTestsDir(app.rules); err != nil {
+		app.printError("Failed to prepare tests directory: %v", err)
+		return
+	}
+
+	startTime := time.Now()
+	processed, err := generator.ProcessGroup(context.Background(), groupsByFile[selectedFile])
+	duration := time.Since(startTime)
+
+	if err != nil {
+		app.printError("Failed to regenerate test for %s: %v", selectedFile, err)
+		if app.flags.strict {
+			app.failed = true
+		}
+		return
+	}
+	if !processed {
+		app.printWarning("Nothing to regenerate for %s", selectedFile)
+		return
+	}
+
+	if err := generator.writeManifest(); err != nil {
+		app.printWarning("Failed to write the test manifest: %v", err)
+	}
+	generator.writeMakefileIfEnabled()
+	app.printSuccess("Regenerated test for %s in %v", selectedFile, duration)
 }
 
 func (app *App) runTests() {
@@ -179,7 +656,7 @@ func (app *App) runTests() {
 	}
 
 	// Run the C++ test workflow using the configured tests and source directories
-	err := RunCppTestWorkflow(app.rules.Paths.TestsDir, app.rules.Paths.CodebaseDir)
+	err := RunCppTestWorkflow(app.rules.TestSearchDirs(), app.rules.Paths.CodebaseDir, app.rules)
 	if err != nil {
 		app.printError("Test execution failed: %v", err)
 		return
@@ -240,9 +717,8 @@ func (app *App) runBuild() {
 }
 
 func (app *App) initializeOllamaClient() (*api.Client, error) {
-	ollamaURL := os.Getenv("OLLAMA_HOST")
-	if ollamaURL == "" {
-		ollamaURL = "http://localhost:11434"
+	ollamaURL := ollamaHost()
+	if os.Getenv("OLLAMA_HOST") == "" {
 		if app.debug {
 			app.printDebug("OLLAMA_HOST not set, using default: %s", ollamaURL)
 		}
@@ -264,18 +740,34 @@ func (app *App) initializeOllamaClient() (*api.Client, error) {
 	return client, nil
 }
 
+// buildDir returns the configured build directory, defaulting to "build"
+func (app *App) buildDir() string {
+	if app.rules.Build.BuildDir == "" {
+		return "build"
+	}
+	return app.rules.Build.BuildDir
+}
+
 func (app *App) buildCMakeProject() {
 	app.printInfo("🏗️  Building CMake project...")
+	buildDir := app.buildDir()
 
 	// Create build directory if it doesn't exist
-	if err := os.MkdirAll("build", 0755); err != nil {
+	if err := os.MkdirAll(buildDir, 0755); err != nil {
 		app.printError("Failed to create build directory: %v", err)
 		return
 	}
 
+	// The build directory may be nested, so point CMake at the project root explicitly
+	projectRoot, err := filepath.Abs(".")
+	if err != nil {
+		app.printError("Failed to get project root: %v", err)
+		return
+	}
+
 	// Configure with CMake
-	configCmd := exec.Command("cmake", "..", "-DCMAKE_BUILD_TYPE=Debug")
-	configCmd.Dir = "build"
+	configCmd := exec.Command("cmake", projectRoot, "-DCMAKE_BUILD_TYPE=Debug")
+	configCmd.Dir = buildDir
 	configCmd.Stdout = os.Stdout
 	configCmd.Stderr = os.Stderr
 
@@ -287,13 +779,13 @@ func (app *App) buildCMakeProject() {
 
 	// Build the project
 	buildCmd := exec.Command("cmake", "--build", ".")
-	buildCmd.Dir = "build"
+	buildCmd.Dir = buildDir
 	buildCmd.Stdout = os.Stdout
 	buildCmd.Stderr = os.Stderr
 
 	app.printInfo("Building project...")
 	startTime := time.Now()
-	err := buildCmd.Run()
+	err = buildCmd.Run()
 	duration := time.Since(startTime)
 
 	if err != nil {
@@ -333,7 +825,8 @@ func (app *App) directCompile() {
 	}
 
 	// Create build directory
-	if err := os.MkdirAll("build", 0755); err != nil {
+	buildDir := app.buildDir()
+	if err := os.MkdirAll(buildDir, 0755); err != nil {
 		app.printError("Failed to create build directory: %v", err)
 		return
 	}
@@ -349,7 +842,7 @@ func (app *App) directCompile() {
 			continue
 		}
 
-		outputFile := "build/" + strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(file, ".cpp"), ".cc"), ".cxx")
+		outputFile := filepath.Join(buildDir, strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(file, ".cpp"), ".cc"), ".cxx"))
 
 		compileCmd := exec.Command(compiler, "-std=c++17", "-Wall", "-g", "-o", outputFile, file)
 		compileCmd.Stdout = os.Stdout
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// makefileName is the Makefile written into TestsDir
+const makefileName = "Makefile"
+
+// writeMakefileIfEnabled rewrites the tests Makefile once the run's tests are saved, when
+// Output.GenerateMakefile is set
+func (tg *TestGenerator) writeMakefileIfEnabled() {
+	if !tg.rules.Output.GenerateMakefile || tg.options.DryRun {
+		return
+	}
+	if err := tg.updateMakefile(); err != nil {
+		fmt.Printf("⚠️  Failed to update the tests Makefile: %v\n", err)
+	}
+}
+
+// updateMakefile rewrites the Makefile in TestsDir with a target for every generated test,
+// compiled with the same flags, sources and libraries the test runner uses
+func (tg *TestGenerator) updateMakefile() error {
+	tg.makefileMu.Lock()
+	defer tg.makefileMu.Unlock()
+
+	testsDir := tg.rules.Paths.TestsDir
+	testFiles, err := ListCppTestFiles(testsDir)
+	if err != nil {
+		return fmt.Errorf("failed to list test files: %v", err)
+	}
+
+	var targets, testSources []string
+	for _, testFile := range testFiles {
+		data, err := os.ReadFile(testFile)
+		if err != nil || !strings.Contains(string(data), generatedMarker) {
+			continue
+		}
+		relPath, err := filepath.Rel(testsDir, testFile)
+		if err != nil {
+			continue
+		}
+		testSources = append(testSources, filepath.ToSlash(relPath))
+		targets = append(targets, strings.TrimSuffix(filepath.ToSlash(relPath), filepath.Ext(relPath)))
+	}
+
+	content, err := renderMakefile(targets, testSources, tg.rules)
+	if err != nil {
+		return err
+	}
+
+	makefilePath := filepath.Join(testsDir, makefileName)
+	if err := tg.checkOverwrite(makefilePath); err != nil {
+		return err
+	}
+	if err := os.WriteFile(makefilePath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", makefilePath, err)
+	}
+	tg.recordSupportFile(makefilePath)
+	return nil
+}
+
+// renderMakefile builds a Makefile with all, test and clean targets for the given tests
+func renderMakefile(targets, testSources []string, rules *Rules) (string, error) {
+	sourceDir := rules.Paths.CodebaseDir
+	flags, err := testCompileFlags(sourceDir, rules)
+	if err != nil {
+		return "", err
+	}
+	includeArgs, err := testIncludeArgs(sourceDir, rules)
+	if err != nil {
+		return "", err
+	}
+
+	var sourceFiles []string
+	if !rules.UsesPrebuiltLibraries() {
+		if sourceFiles, err = ListSourceFiles(sourceDir); err != nil {
+			return "", fmt.Errorf("failed to list source files: %v", err)
+		}
+	}
+	extraSources, err := ExpandExtraSources(rules.Build.ExtraSources, sourceFiles)
+	if err != nil {
+		return "", err
+	}
+	sourceFiles = append(sourceFiles, extraSources...)
+
+	// C++ sources are compiled with each test, other languages into objects with their own compiler
+	var cppSources []string
+	var objectRules strings.Builder
+	var objects []string
+	for _, sourceFile := range sourceFiles {
+		absSourceFile, err := filepath.Abs(sourceFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to get absolute path for %s: %v", sourceFile, err)
+		}
+		profile, ok := languageProfileFor(absSourceFile, rules)
+		if !ok || profile.compiledAsCpp() {
+			cppSources = append(cppSources, absSourceFile)
+			continue
+		}
+
+		base := strings.TrimSuffix(filepath.Base(absSourceFile), filepath.Ext(absSourceFile))
+		object := base + "_" + strings.ToLower(profile.Language) + ".o"
+		objects = append(objects, object)
+		objectFlags := []string{"-g", "-O0"}
+		if !rules.Build.NoCoverage {
+			objectFlags = append(objectFlags, "--coverage")
+		}
+		if profile.Standard != "" {
+			objectFlags = append(objectFlags, "-std="+profile.Standard)
+		}
+		objectFlags = append(objectFlags, includeArgs...)
+		fmt.Fprintf(&objectRules, "%s: %s\n\t%s %s -c $< -o $@\n\n", object, absSourceFile, profile.Compiler, strings.Join(objectFlags, " "))
+	}
+
+	if rules.Mocks.StubsDir != "" {
+		stubArgs, err := stubCompileArgs(rules.Mocks.StubsDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to add stubs: %v", err)
+		}
+		for _, arg := range stubArgs {
+			if strings.HasPrefix(arg, "-I") {
+				flags = append(flags, arg)
+			} else {
+				cppSources = append(cppSources, arg)
+			}
+		}
+	}
+	libraryArgs, err := libraryLinkArgs(rules.Build.LinkLibraries)
+	if err != nil {
+		return "", err
+	}
+	libs := libraryArgs
+	for _, arg := range rapidCheckCompileArgs(rules) {
+		if strings.HasPrefix(arg, "-I") {
+			flags = append(flags, arg)
+		} else {
+			libs = append(libs, arg)
+		}
+	}
+	// Google Test is linked through GTEST_LIBS, so the Makefile can be written before it is built
+	gtestLibs, err := makefileGTestLibs(rules)
+	if err != nil {
+		return "", err
+	}
+	if gtestLibs != "" {
+		libs = append(libs, "$(GTEST_LIBS)")
+	}
+	libs = append(libs, frameworkExtraLinkFlags(rules)...)
+
+	sort.Strings(cppSources)
+
+	var out strings.Builder
+	out.WriteString("# " + generatedMarkerText + "\n")
+	out.WriteString("# Builds and runs the generated tests: make, make test, make clean\n\n")
+	out.WriteString("CXX ?= g++\n")
+	fmt.Fprintf(&out, "CXXFLAGS = %s\n", strings.Join(flags, " "))
+	fmt.Fprintf(&out, "SOURCES = %s\n", strings.Join(cppSources, " "))
+	fmt.Fprintf(&out, "OBJECTS = %s\n", strings.Join(objects, " "))
+	if gtestLibs != "" {
+		fmt.Fprintf(&out, "GTEST_LIBS ?= %s\n", gtestLibs)
+	}
+	fmt.Fprintf(&out, "LDLIBS = %s\n", strings.Join(libs, " "))
+	fmt.Fprintf(&out, "TESTS = %s\n\n", strings.Join(targets, " "))
+	out.WriteString(".PHONY: all test clean\n\n")
+	out.WriteString("all: $(TESTS)\n\n")
+	out.WriteString("test: all\n\t@for t in $(TESTS); do echo \"Running $$t\"; ./$$t || exit 1; done\n\n")
+	out.WriteString("clean:\n\trm -f $(TESTS) $(OBJECTS) *.gcda *.gcno\n\n")
+	for i, target := range targets {
+		// Tests in subdirectories also see the headers of the source directory they mirror
+		absTestSource, err := filepath.Abs(filepath.Join(rules.Paths.TestsDir, testSources[i]))
+		if err != nil {
+			return "", fmt.Errorf("failed to get absolute path for %s: %v", testSources[i], err)
+		}
+		if mirrored := mirroredIncludeArgs(absTestSource, sourceDir, rules); len(mirrored) > 0 {
+			fmt.Fprintf(&out, "%s: CXXFLAGS += %s\n", target, strings.Join(mirrored, " "))
+		}
+		fmt.Fprintf(&out, "%s: %s $(SOURCES) $(OBJECTS)\n", target, testSources[i])
+		out.WriteString("\t$(CXX) $(CXXFLAGS) -o $@ $< $(SOURCES) $(OBJECTS) $(LDLIBS)\n\n")
+	}
+	out.WriteString(objectRules.String())
+
+	return strings.TrimRight(out.String(), "\n") + "\n", nil
+}
+
+// makefileGTestLibs returns the linker flags for Google Test: its libraries by name, looked up
+// in Build.GTestLibDir or the vendored build, which needn't exist yet. It returns "" when the
+// framework doesn't link Google Test.
+func makefileGTestLibs(rules *Rules) (string, error) {
+	if !getFrameworkProfile(rules.TestFramework).LinksGoogleTest {
+		return "", nil
+	}
+	libDir := rules.Build.GTestLibDir
+	if libDir == "" {
+		libDir = filepath.Join("external", "googletest", "build", "lib")
+	}
+	absLibDir, err := filepath.Abs(libDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for %s: %v", libDir, err)
+	}
+
+	libs := []string{"-L" + absLibDir}
+	// gMock matchers live in libgmock, which has to come before the gtest libraries
+	if rules.Assertions.UseMatchers {
+		libs = append(libs, "-lgmock")
+	}
+	return strings.Join(append(libs, "-lgtest", "-lgtest_main"), " "), nil
+}
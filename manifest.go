@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// manifestFile maps the sources to their generated tests, kept in TestsDir
+const manifestFile = "manifest.json"
+
+// manifestVersion is the manifest.json format version; version 2 hashes every file of the
+// source's group instead of only the source itself
+const manifestVersion = 2
+
+// manifestEntry relates one source file to the test generated for it
+type manifestEntry struct {
+	Source      string `json:"source"`
+	Test        string `json:"test"`
+	Model       string `json:"model"`
+	GeneratedAt string `json:"generated_at"`
+	// SourceHashes maps each file the test was generated from, the source and the headers
+	// grouped with it, to the SHA-256 of its content
+	SourceHashes map[string]string `json:"source_hashes,omitempty"`
+}
+
+// testManifest is the content of manifest.json
+type testManifest struct {
+	Version int             `json:"version"`
+	Files   []manifestEntry `json:"files"`
+}
+
+// recordManifestEntry remembers the test saved for a source file during this run, generated
+// from groupFiles. Their hashes let tooling tell when a test is out of date.
+func (tg *TestGenerator) recordManifestEntry(source string, groupFiles []string, test, model string) {
+	entry := manifestEntry{
+		Source:      filepath.ToSlash(source),
+		Test:        filepath.ToSlash(test),
+		Model:       model,
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, file := range groupFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if entry.SourceHashes == nil {
+			entry.SourceHashes = make(map[string]string)
+		}
+		sum := sha256.Sum256(data)
+		entry.SourceHashes[filepath.ToSlash(file)] = hex.EncodeToString(sum[:])
+	}
+
+	tg.manifestMu.Lock()
+	defer tg.manifestMu.Unlock()
+	tg.manifest = append(tg.manifest, entry)
+}
+
+// writeManifest merges the tests saved during this run into TestsDir/manifest.json. Entries of
+// earlier runs are kept unless their source got a new test; entries are sorted by source so
+// the file only changes where tests did.
+func (tg *TestGenerator) writeManifest() error {
+	tg.manifestMu.Lock()
+	defer tg.manifestMu.Unlock()
+	if len(tg.manifest) == 0 {
+		return nil
+	}
+
+	manifestPath := filepath.Join(tg.rules.Paths.TestsDir, manifestFile)
+	bySource := make(map[string]manifestEntry)
+	if data, err := os.ReadFile(manifestPath); err == nil {
+		var existing testManifest
+		if err := json.Unmarshal(data, &existing); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", manifestPath, err)
+		}
+		for _, entry := range existing.Files {
+			bySource[entry.Source] = entry
+		}
+	}
+	for _, entry := range tg.manifest {
+		bySource[entry.Source] = entry
+	}
+
+	manifest := testManifest{Version: manifestVersion}
+	for _, entry := range bySource {
+		manifest.Files = append(manifest.Files, entry)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Source < manifest.Files[j].Source })
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	if err := os.WriteFile(manifestPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", manifestPath, err)
+	}
+	tg.recordSupportFile(manifestPath)
+	log.Printf("Wrote manifest: %s (%d files)", manifestPath, len(manifest.Files))
+	return nil
+}
+
+// manifestListsTest reports whether manifest.json lists path as a generated test, which lets
+// generated tests that lost their marker, to a formatter for example, be regenerated. The
+// manifest is read once per run.
+func (tg *TestGenerator) manifestListsTest(path string) bool {
+	tg.manifestTestsOnce.Do(func() {
+		data, err := os.ReadFile(filepath.Join(tg.rules.Paths.TestsDir, manifestFile))
+		if err != nil {
+			return
+		}
+		var existing testManifest
+		if err := json.Unmarshal(data, &existing); err != nil {
+			log.Printf("Ignoring unreadable manifest: %v", err)
+			return
+		}
+		tg.manifestTests = make(map[string]bool)
+		for _, entry := range existing.Files {
+			if absTest, err := filepath.Abs(filepath.FromSlash(entry.Test)); err == nil {
+				tg.manifestTests[absTest] = true
+			}
+		}
+	})
+
+	absPath, err := filepath.Abs(path)
+	return err == nil && tg.manifestTests[absPath]
+}
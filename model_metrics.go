@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// modelMetrics holds the token counts and timing Ollama reports for generation requests
+type modelMetrics struct {
+	Model           string // model that produced the accepted response, if any
+	Calls           int
+	PromptEvalCount int
+	EvalCount       int
+	TotalDuration   time.Duration
+}
+
+// fileMetrics records the model usage spent generating the test for one file
+type fileMetrics struct {
+	File    string
+	Metrics modelMetrics
+}
+
+// metricsFromResponse extracts the metrics of a single, completed generate or chat request
+func metricsFromResponse(resp api.Metrics) modelMetrics {
+	return modelMetrics{
+		Calls:           1,
+		PromptEvalCount: resp.PromptEvalCount,
+		EvalCount:       resp.EvalCount,
+		TotalDuration:   resp.TotalDuration,
+	}
+}
+
+// add accumulates other into m
+func (m *modelMetrics) add(other modelMetrics) {
+	if other.Model != "" {
+		m.Model = other.Model
+	}
+	m.Calls += other.Calls
+	m.PromptEvalCount += other.PromptEvalCount
+	m.EvalCount += other.EvalCount
+	m.TotalDuration += other.TotalDuration
+}
+
+// String formats the metrics for the console
+func (m modelMetrics) String() string {
+	return fmt.Sprintf("%d prompt tokens, %d generated tokens, %v over %d calls",
+		m.PromptEvalCount, m.EvalCount, m.TotalDuration.Round(time.Millisecond), m.Calls)
+}
+
+// recordMetrics stores the model usage of a generated file for the run summary
+func (tg *TestGenerator) recordMetrics(filename string, metrics modelMetrics) {
+	log.Printf("Model usage for %s: %s", filename, metrics)
+
+	tg.metricsMu.Lock()
+	defer tg.metricsMu.Unlock()
+	tg.metrics = append(tg.metrics, fileMetrics{File: filename, Metrics: metrics})
+}
+
+// totalMetrics returns the model usage summed over all files and the number of files recorded
+func (tg *TestGenerator) totalMetrics() (modelMetrics, int) {
+	tg.metricsMu.Lock()
+	defer tg.metricsMu.Unlock()
+
+	var total modelMetrics
+	for _, entry := range tg.metrics {
+		total.add(entry.Metrics)
+	}
+	return total, len(tg.metrics)
+}
+
+// printMetricsSummary writes the per-file and total model usage of the run to the console
+func (tg *TestGenerator) printMetricsSummary() {
+	tg.metricsMu.Lock()
+	defer tg.metricsMu.Unlock()
+
+	if len(tg.metrics) == 0 {
+		return
+	}
+
+	var total modelMetrics
+	fmt.Println("\n📈 Model usage:")
+	for _, entry := range tg.metrics {
+		fmt.Printf("  %s: %s\n", entry.File, entry.Metrics)
+		total.add(entry.Metrics)
+	}
+	fmt.Printf("  Total: %s\n", total)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// pchStandardHeaders are the standard headers most generated tests include
+var pchStandardHeaders = []string{
+	"<algorithm>", "<cmath>", "<cstdint>", "<functional>", "<map>", "<memory>",
+	"<sstream>", "<stdexcept>", "<string>", "<vector>",
+}
+
+var (
+	// testPCHs holds the precompiled header built for each set of compile flags in this run,
+	// "" when building it failed
+	testPCHs   = make(map[string]string)
+	testPCHsMu sync.Mutex
+)
+
+// testPCHContent returns the header that is precompiled: the framework headers the tests use
+// and common standard headers
+func testPCHContent(rules *Rules) string {
+	profile := getFrameworkProfile(rules.TestFramework)
+	var content strings.Builder
+	content.WriteString("// " + generatedMarkerText + ": precompiled test header, included once with -include\n\n")
+	content.WriteString(profile.MainInclude + "\n")
+	if rules.Assertions.UseMatchers && profile.MatcherInclude != "" {
+		content.WriteString(profile.MatcherInclude + "\n")
+	}
+	for _, header := range pchStandardHeaders {
+		content.WriteString("#include " + header + "\n")
+	}
+	return content.String()
+}
+
+// PrepareTestPCH builds the precompiled header during setup, next to CheckAndBuildGoogleTest,
+// so test compiles only pick it up. It does nothing unless Build.UsePCH is set.
+func PrepareTestPCH(sourceDir string, rules *Rules) {
+	if !rules.Build.UsePCH {
+		return
+	}
+	flags, err := testCompileFlags(sourceDir, rules)
+	if err != nil {
+		fmt.Printf("⚠️  Failed to get test compile flags, compiling without a precompiled header: %v\n", err)
+		return
+	}
+	BuildTestPCH(flags, rules)
+}
+
+// BuildTestPCH precompiles the common test includes with the flags tests are compiled with and
+// returns the header it was built from, with the .gch next to it. It is built once per run for
+// each set of flags; "" is returned when Build.UsePCH is off or building failed, and tests then
+// compile without it.
+func BuildTestPCH(flags []string, rules *Rules) string {
+	if !rules.Build.UsePCH {
+		return ""
+	}
+
+	content := testPCHContent(rules)
+	sum := sha256.Sum256([]byte(strings.Join(flags, "\x00") + "\x00" + content))
+	key := hex.EncodeToString(sum[:8])
+
+	testPCHsMu.Lock()
+	defer testPCHsMu.Unlock()
+	if header, ok := testPCHs[key]; ok {
+		return header
+	}
+	testPCHs[key] = ""
+
+	baseDir := rules.Paths.TempDir
+	if baseDir == "" {
+		baseDir = os.TempDir()
+	}
+	pchDir, err := filepath.Abs(filepath.Join(baseDir, "utg-pch", key))
+	if err != nil {
+		fmt.Printf("⚠️  Failed to get precompiled header directory, compiling without it: %v\n", err)
+		return ""
+	}
+	if err := os.MkdirAll(pchDir, 0755); err != nil {
+		fmt.Printf("⚠️  Failed to create precompiled header directory, compiling without it: %v\n", err)
+		return ""
+	}
+
+	header := filepath.Join(pchDir, "utg_pch.h")
+	if err := os.WriteFile(header, []byte(content), 0644); err != nil {
+		fmt.Printf("⚠️  Failed to write precompiled header source, compiling without it: %v\n", err)
+		return ""
+	}
+
+	fmt.Println("🔧 Building precompiled header for test compiles...")
+	args := append(append([]string{}, flags...), "-x", "c++-header", header, "-o", header+".gch")
+	buildCmd := exec.Command("g++", args...)
+	buildCmd.Dir = pchDir
+	if output, err := buildCmd.CombinedOutput(); err != nil {
+		fmt.Printf("⚠️  Precompiled header build failed, compiling without it: %v\n%s\n", err, string(output))
+		return ""
+	}
+
+	fmt.Printf("✅ Precompiled header built: %s.gch\n", header)
+	testPCHs[key] = header
+	return header
+}
+
+var (
+	// compilerClang records whether g++ is clang in disguise, as on macOS
+	compilerClang     bool
+	compilerClangOnce sync.Once
+)
+
+// isClangCompiler reports whether the g++ on the PATH is clang
+func isClangCompiler() bool {
+	compilerClangOnce.Do(func() {
+		output, err := exec.Command("g++", "--version").CombinedOutput()
+		compilerClang = err == nil && strings.Contains(strings.ToLower(string(output)), "clang")
+	})
+	return compilerClang
+}
+
+// pchIncludeArgs returns the flags that load the precompiled header built from header into a
+// test compile: -include-pch for clang, and for GCC, which has no such flag, -include of the
+// header, which makes it use the .gch next to it. It returns nil when there is no header.
+func pchIncludeArgs(header string) []string {
+	if header == "" {
+		return nil
+	}
+	if isClangCompiler() {
+		return []string{"-include-pch", header + ".gch"}
+	}
+	return []string{"-include", header}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// minDataTableLines is the size from which an initializer list counts as a data table that
+// can be shortened when the code has to shrink
+const minDataTableLines = 8
+
+// keptDataTableLines is how many lines of a shortened data table stay in the prompt
+const keptDataTableLines = 3
+
+// promptCode returns the code as it is put in the prompt. Comments are removed with
+// LLMPromptGuidance.StripComments, and code larger than LLMPromptGuidance.MaxCodeBytes also
+// loses its comments and has its large data tables shortened. Declarations, signatures and
+// function bodies are always kept.
+func (tg *TestGenerator) promptCode(code string) string {
+	guidance := tg.rules.LLMPromptGuidance
+	tooLarge := guidance.MaxCodeBytes > 0 && len(code) > guidance.MaxCodeBytes
+	if !guidance.StripComments && !tooLarge {
+		return code
+	}
+
+	original := len(code)
+	code = stripComments(code)
+	if tooLarge && len(code) > guidance.MaxCodeBytes {
+		code = shortenDataTables(code)
+	}
+	log.Printf("Reduced the code in the prompt from %d to %d bytes", original, len(code))
+	if guidance.MaxCodeBytes > 0 && len(code) > guidance.MaxCodeBytes {
+		fmt.Printf("⚠️  Code is still %d bytes after stripping comments and data tables (max_code_bytes %d)\n", len(code), guidance.MaxCodeBytes)
+	}
+	return code
+}
+
+// stripComments removes // and /* */ comments from code, keeping string, raw string and
+// character literals and preprocessor directives intact, and collapses the blank lines left
+// behind. A comment within a line is replaced by a space, so the tokens around it stay apart.
+func stripComments(code string) string {
+	var out strings.Builder
+	n := len(code)
+
+	for i := 0; i < n; i++ {
+		c := code[i]
+		switch {
+		case c == '/' && i+1 < n && code[i+1] == '/':
+			for i+1 < n && code[i+1] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < n && code[i+1] == '*':
+			i += 2
+			multiline := false
+			for i+1 < n && !(code[i] == '*' && code[i+1] == '/') {
+				if code[i] == '\n' {
+					out.WriteByte('\n')
+					multiline = true
+				}
+				i++
+			}
+			i++
+			// "int/* count */n" must not become "intn"
+			if !multiline {
+				out.WriteByte(' ')
+			}
+		case c == '"' && rawStringEnd(code, i) >= 0:
+			// Raw strings have no escapes and may hold anything that looks like a comment
+			end := rawStringEnd(code, i)
+			out.WriteString(code[i : end+1])
+			i = end
+		case c == '"' || c == '\'':
+			quote := c
+			out.WriteByte(c)
+			for i++; i < n && code[i] != quote; i++ {
+				if code[i] == '\\' && i+1 < n {
+					out.WriteByte(code[i])
+					i++
+				}
+				out.WriteByte(code[i])
+			}
+			if i < n {
+				out.WriteByte(quote)
+			}
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	// Drop trailing whitespace and runs of blank lines
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(out.String(), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rawStringEnd returns the offset of the quote closing the raw string literal R"delim(...)delim"
+// whose opening quote is at start, or -1 if no raw string starts there
+func rawStringEnd(code string, start int) int {
+	if start == 0 || code[start-1] != 'R' {
+		return -1
+	}
+	// The R may follow an encoding prefix (u8R, uR, UR, LR) but not be the end of an identifier
+	prefixStart := start - 1
+	for prefixStart > 0 && strings.IndexByte("u8UL", code[prefixStart-1]) >= 0 {
+		prefixStart--
+	}
+	if prefixStart > 0 && isIdentifierByte(code[prefixStart-1]) {
+		return -1
+	}
+
+	open := strings.IndexByte(code[start+1:], '(')
+	if open < 0 || open > 16 {
+		return -1
+	}
+	delimiter := code[start+1 : start+1+open]
+	if strings.ContainsAny(delimiter, " \\)\t\n\"") {
+		return -1
+	}
+	closing := ")" + delimiter + "\""
+	end := strings.Index(code[start+2+open:], closing)
+	if end < 0 {
+		return -1
+	}
+	return start + 2 + open + end + len(closing) - 1
+}
+
+// isIdentifierByte reports whether b can be part of a C++ identifier
+func isIdentifierByte(b byte) bool {
+	return b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+// dataTablePattern matches the opening brace of an initializer, e.g. "table[] = {"
+var dataTablePattern = regexp.MustCompile(`=\s*\{[ \t]*\n`)
+
+// shortenDataTables keeps only the first lines of large initializer lists without statements,
+// such as lookup tables, replacing the rest with a comment saying how much was left out
+func shortenDataTables(code string) string {
+	var out strings.Builder
+	rest := code
+	for {
+		loc := dataTablePattern.FindStringIndex(rest)
+		if loc == nil {
+			out.WriteString(rest)
+			return out.String()
+		}
+
+		bodyStart := loc[1]
+		bodyEnd := matchingBrace(rest, bodyStart)
+		out.WriteString(rest[:bodyStart])
+		if bodyEnd < 0 {
+			out.WriteString(rest[bodyStart:])
+			return out.String()
+		}
+
+		body := rest[bodyStart:bodyEnd]
+		lines := strings.Split(strings.TrimRight(body, " \t\n"), "\n")
+		if len(lines) >= minDataTableLines && !strings.ContainsAny(stripCommentsAndStrings(body), ";") {
+			indent := lines[0][:len(lines[0])-len(strings.TrimLeft(lines[0], " \t"))]
+			out.WriteString(strings.Join(lines[:keptDataTableLines], "\n"))
+			out.WriteString(fmt.Sprintf("\n%s/* ... %d more lines omitted */\n", indent, len(lines)-keptDataTableLines))
+		} else {
+			out.WriteString(body)
+		}
+		rest = rest[bodyEnd:]
+	}
+}
+
+// matchingBrace returns the offset of the '}' closing the brace opened just before start,
+// skipping string and character literals, or -1 if it isn't closed
+func matchingBrace(code string, start int) int {
+	depth := 1
+	for i := start; i < len(code); i++ {
+		switch code[i] {
+		case '"', '\'':
+			quote := code[i]
+			for i++; i < len(code) && code[i] != quote; i++ {
+				if code[i] == '\\' {
+					i++
+				}
+			}
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
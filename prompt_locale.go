@@ -0,0 +1,110 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// promptLocale holds the instruction scaffolding of the prompt in one language. The detailed
+// requirement lines and the code under test are not translated.
+type promptLocale struct {
+	OutputRequirements string
+	OnlyCode           string
+	NoExplanations     string
+	NoPhrases          string
+	StartWith          string
+	EndWith            string
+	UseFences          string
+	NoFences           string
+
+	// Generate is a format string taking the test framework
+	Generate               string
+	Requirements           string
+	AdditionalRequirements string
+	Example                string
+	ExampleTests           string
+	CodeToTest             string
+	OutputOnly             string
+}
+
+var englishLocale = promptLocale{
+	OutputRequirements:     "IMPORTANT OUTPUT REQUIREMENTS:",
+	OnlyCode:               "- Return ONLY valid C++ test code",
+	NoExplanations:         "- Do NOT include any explanatory text",
+	NoPhrases:              "- Do NOT include phrases like 'Here is', 'This test', etc.",
+	StartWith:              "- Start directly with #include statements or TEST macros",
+	EndWith:                "- End with the last closing brace of the test",
+	UseFences:              "- Use markdown code fences (```cpp and ```)",
+	NoFences:               "- Do NOT use markdown code fences",
+	Generate:               "Generate ONLY the C++ unit test code using %s framework. Do not include any explanations, comments, or text outside the code.",
+	Requirements:           "Requirements:",
+	AdditionalRequirements: "Additional requirements:",
+	Example:                "Example of code and the tests expected for it:",
+	ExampleTests:           "Example tests:",
+	CodeToTest:             "Code to test:",
+	OutputOnly:             "Output only the complete C++ test file code:",
+}
+
+// promptLocales maps the names accepted in LLMPromptGuidance.Language to their locale
+var promptLocales = map[string]promptLocale{
+	"en": englishLocale,
+	"de": {
+		OutputRequirements:     "WICHTIGE ANFORDERUNGEN AN DIE AUSGABE:",
+		OnlyCode:               "- Gib NUR gültigen C++-Testcode zurück",
+		NoExplanations:         "- Füge KEINEN erklärenden Text hinzu",
+		NoPhrases:              "- Verwende KEINE Formulierungen wie 'Hier ist', 'Dieser Test' usw.",
+		StartWith:              "- Beginne direkt mit #include-Anweisungen oder TEST-Makros",
+		EndWith:                "- Ende mit der letzten schließenden Klammer des Tests",
+		UseFences:              "- Verwende Markdown-Codeblöcke (```cpp und ```)",
+		NoFences:               "- Verwende KEINE Markdown-Codeblöcke",
+		Generate:               "Erzeuge NUR den C++-Unit-Test-Code mit dem Framework %s. Füge keine Erklärungen, Kommentare oder Text außerhalb des Codes hinzu.",
+		Requirements:           "Anforderungen:",
+		AdditionalRequirements: "Zusätzliche Anforderungen:",
+		Example:                "Beispiel für Code und die dafür erwarteten Tests:",
+		ExampleTests:           "Beispieltests:",
+		CodeToTest:             "Zu testender Code:",
+		OutputOnly:             "Gib nur den vollständigen Code der C++-Testdatei aus:",
+	},
+	"es": {
+		OutputRequirements:     "REQUISITOS IMPORTANTES DE SALIDA:",
+		OnlyCode:               "- Devuelve SOLO código de prueba C++ válido",
+		NoExplanations:         "- NO incluyas texto explicativo",
+		NoPhrases:              "- NO incluyas frases como 'Aquí está', 'Esta prueba', etc.",
+		StartWith:              "- Empieza directamente con sentencias #include o macros TEST",
+		EndWith:                "- Termina con la última llave de cierre de la prueba",
+		UseFences:              "- Usa bloques de código markdown (```cpp y ```)",
+		NoFences:               "- NO uses bloques de código markdown",
+		Generate:               "Genera SOLO el código de pruebas unitarias en C++ usando el framework %s. No incluyas explicaciones, comentarios ni texto fuera del código.",
+		Requirements:           "Requisitos:",
+		AdditionalRequirements: "Requisitos adicionales:",
+		Example:                "Ejemplo de código y de las pruebas esperadas para él:",
+		ExampleTests:           "Pruebas de ejemplo:",
+		CodeToTest:             "Código a probar:",
+		OutputOnly:             "Devuelve solo el código completo del archivo de pruebas C++:",
+	},
+}
+
+// promptLocaleAliases maps language names to locale codes
+var promptLocaleAliases = map[string]string{
+	"english": "en",
+	"german":  "de",
+	"deutsch": "de",
+	"spanish": "es",
+	"español": "es",
+}
+
+// promptLocale returns the locale selected by LLMPromptGuidance.Language, English by default
+func (tg *TestGenerator) promptLocale() promptLocale {
+	language := strings.ToLower(strings.TrimSpace(tg.rules.LLMPromptGuidance.Language))
+	if language == "" {
+		return englishLocale
+	}
+	if code, ok := promptLocaleAliases[language]; ok {
+		language = code
+	}
+	if locale, ok := promptLocales[language]; ok {
+		return locale
+	}
+	log.Printf("Unknown prompt language %q, using English", tg.rules.LLMPromptGuidance.Language)
+	return englishLocale
+}
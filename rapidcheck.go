@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// rapidCheckInstall is a RapidCheck install found on disk
+type rapidCheckInstall struct {
+	IncludeDirs []string
+	Library     string
+}
+
+// findRapidCheck looks for RapidCheck in Build.RapidCheckDir, or else in external/rapidcheck
+// and the system prefixes. Both install trees (include/, lib/) and source checkouts built in
+// build/ are recognized.
+func findRapidCheck(rules *Rules) (rapidCheckInstall, error) {
+	prefixes := []string{filepath.Join("external", "rapidcheck"), "/usr/local", "/usr"}
+	if rules.Build.RapidCheckDir != "" {
+		prefixes = []string{rules.Build.RapidCheckDir}
+	}
+
+	for _, prefix := range prefixes {
+		absPrefix, err := filepath.Abs(prefix)
+		if err != nil {
+			return rapidCheckInstall{}, fmt.Errorf("failed to get absolute path for %s: %v", prefix, err)
+		}
+
+		includeDir := filepath.Join(absPrefix, "include")
+		if _, err := os.Stat(filepath.Join(includeDir, "rapidcheck.h")); err != nil {
+			continue
+		}
+		install := rapidCheckInstall{IncludeDirs: []string{includeDir}}
+
+		// Source checkouts keep the gtest integration header apart
+		if _, err := os.Stat(filepath.Join(includeDir, "rapidcheck", "gtest.h")); err != nil {
+			extrasDir := filepath.Join(absPrefix, "extras", "gtest", "include")
+			if _, err := os.Stat(filepath.Join(extrasDir, "rapidcheck", "gtest.h")); err != nil {
+				continue
+			}
+			install.IncludeDirs = append(install.IncludeDirs, extrasDir)
+		}
+
+		for _, libDir := range []string{"lib", "lib64", filepath.Join("lib", "x86_64-linux-gnu"), "build"} {
+			library := filepath.Join(absPrefix, libDir, "librapidcheck.a")
+			if _, err := os.Stat(library); err == nil {
+				install.Library = library
+				return install, nil
+			}
+		}
+	}
+
+	if rules.Build.RapidCheckDir != "" {
+		return rapidCheckInstall{}, fmt.Errorf("RapidCheck with gtest support not found in rapidcheck_dir %s", rules.Build.RapidCheckDir)
+	}
+	return rapidCheckInstall{}, fmt.Errorf("RapidCheck with gtest support not found")
+}
+
+// rapidCheckCompileArgs returns the include and link flags for RapidCheck when property-based
+// tests are enabled and RapidCheck is installed, and none otherwise
+func rapidCheckCompileArgs(rules *Rules) []string {
+	if !rules.TestCaseRules.PropertyBased {
+		return nil
+	}
+	install, err := findRapidCheck(rules)
+	if err != nil {
+		// The generator asked for no properties then, so the tests build without it
+		return nil
+	}
+
+	var args []string
+	for _, includeDir := range install.IncludeDirs {
+		args = append(args, "-I"+includeDir)
+	}
+	return append(args, install.Library)
+}
+
+// enablePropertyTests switches the generator to property-based tests if the rules ask for them,
+// the framework has a RapidCheck integration and RapidCheck is installed
+func (tg *TestGenerator) enablePropertyTests() {
+	if !tg.rules.TestCaseRules.PropertyBased {
+		return
+	}
+	if len(tg.framework.PropertyMacros) == 0 {
+		fmt.Printf("⚠️  Property-based tests are not supported with %s, generating example tests only\n", tg.framework.Name)
+		return
+	}
+	if _, err := findRapidCheck(tg.rules); err != nil {
+		fmt.Printf("⚠️  %v, generating example tests only\n", err)
+		return
+	}
+	tg.propertyBased = true
+	tg.framework = tg.framework.withTestMacros(tg.framework.PropertyMacros...)
+}
+
+// propertyGuidance returns the prompt lines asking for RapidCheck properties next to the example tests
+func (tg *TestGenerator) propertyGuidance(info SourceInfo) string {
+	if !tg.propertyBased || len(info.Functions) == 0 {
+		return ""
+	}
+	return "- For pure functions, numeric ones in particular, also write RapidCheck properties next to the example tests: " +
+		"RC_GTEST_PROP(Suite, Name, (int a, int b)) { ... RC_ASSERT(condition); }. State invariants that hold for " +
+		"every input (round trips, symmetry, bounds, agreement with a simpler implementation) and use RC_PRE to " +
+		"discard inputs outside a function's domain\n" +
+		"- Only write properties for functions without side effects; functions with state or I/O keep example tests only\n"
+}
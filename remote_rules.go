@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// remoteRulesTimeout bounds fetching rules from a URL
+const remoteRulesTimeout = 15 * time.Second
+
+// remoteRulesTokenEnv names the environment variable with a bearer token for remote rules
+const remoteRulesTokenEnv = "UTG_RULES_TOKEN"
+
+// isRemoteRules reports whether a rules path is an http(s) URL
+func isRemoteRules(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// readRulesSource returns the YAML of a rules file or URL. Fetched rules are cached, and the
+// cached copy is used when the server can't be reached.
+func readRulesSource(path string) ([]byte, error) {
+	if !isRemoteRules(path) {
+		return os.ReadFile(path)
+	}
+
+	cachePath := remoteRulesCachePath(path)
+	data, err := fetchRemoteRules(path)
+	if err == nil {
+		if cachePath != "" {
+			if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+				os.WriteFile(cachePath, data, 0644)
+			}
+		}
+		return data, nil
+	}
+
+	if cachePath != "" {
+		if info, statErr := os.Stat(cachePath); statErr == nil {
+			if cached, readErr := os.ReadFile(cachePath); readErr == nil {
+				fmt.Printf("⚠️  %v, using the copy cached %s\n", err, info.ModTime().Format(time.RFC1123))
+				return cached, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+// fetchRemoteRules downloads rules over HTTP, sending UTG_RULES_TOKEN as a bearer token when
+// set. Credentials in the URL itself are sent as basic auth.
+func fetchRemoteRules(rulesURL string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rulesURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules URL %s: %v", redactURL(rulesURL), err)
+	}
+	if token := os.Getenv(remoteRulesTokenEnv); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: remoteRulesTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rules from %s: %v", redactURL(rulesURL), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch rules from %s: %s", redactURL(rulesURL), resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules from %s: %v", redactURL(rulesURL), err)
+	}
+	return data, nil
+}
+
+// remoteRulesCachePath returns where the rules fetched from a URL are cached, or "" when
+// there is no cache directory
+func remoteRulesCachePath(rulesURL string) string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(rulesURL))
+	return filepath.Join(cacheDir, "utg", "rules-"+hex.EncodeToString(sum[:8])+".yaml")
+}
+
+// redactURL removes credentials from a rules URL before it is printed; file paths are kept
+func redactURL(rawURL string) string {
+	if !isRemoteRules(rawURL) {
+		return rawURL
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "the rules URL"
+	}
+	return parsed.Redacted()
+}
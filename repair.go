@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+)
+
+// maxRepairErrorBytes bounds how much compiler output is sent back to the model
+const maxRepairErrorBytes = 8000
+
+// CompileCheckTest compiles a single test in an isolated directory without running it and
+// returns the compiler's error output if it fails to build
+func CompileCheckTest(testFile string, sourceDir string, rules *Rules) (string, error) {
+	baseDir := rules.Paths.TempDir
+	if baseDir == "" {
+		baseDir = os.TempDir()
+	}
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %v", err)
+	}
+
+	workDir, err := os.MkdirTemp(baseDir, "utg-repair-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create working directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	absTestFile, err := filepath.Abs(testFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for test file: %v", err)
+	}
+
+	err = compileCppTest(absTestFile, sourceDir, workDir, "repair_check", rules)
+	var compileErr *compileError
+	if errors.As(err, &compileErr) {
+		return compileErr.Output, nil
+	}
+	return "", err
+}
+
+// repairCompileErrors compiles the saved test and, while it fails to build, sends the compiler
+// errors to the repair model and saves its corrected test. It stops after
+// ModelConfig.RepairRounds rounds and returns the latest test code.
+func (tg *TestGenerator) repairCompileErrors(ctx context.Context, filename, content, outputPath, testCode string) string {
+	rounds := tg.rules.ModelConfig.RepairRounds
+	for round := 1; round <= rounds; round++ {
+		compileOutput, err := CompileCheckTest(outputPath, tg.rules.Paths.CodebaseDir, tg.rules)
+		if err != nil {
+			fmt.Printf("⚠️  Compile repair for %s stopped: %v\n", filename, err)
+			return testCode
+		}
+		if compileOutput == "" {
+			if round > 1 {
+				fmt.Printf("🔧 %s compiles after %d repair rounds\n", outputPath, round-1)
+			}
+			return testCode
+		}
+
+		fmt.Printf("🔧 %s does not compile, repair round %d/%d...\n", outputPath, round, rounds)
+		repaired, metrics, err := tg.requestRepair(ctx, content, testCode, compileOutput)
+		tg.recordMetrics(filename, metrics)
+		if err != nil {
+			fmt.Printf("⚠️  Compile repair for %s failed: %v\n", filename, err)
+			return testCode
+		}
+
+		testCode = tg.finishTestCode(repaired, filename, content)
+		if err := tg.saveTestFile(outputPath, testCode); err != nil {
+			fmt.Printf("⚠️  Failed to save repaired test %s: %v\n", outputPath, err)
+			return testCode
+		}
+	}
+
+	if compileOutput, err := CompileCheckTest(outputPath, tg.rules.Paths.CodebaseDir, tg.rules); err == nil && compileOutput != "" {
+		fmt.Printf("⚠️  %s still does not compile after %d repair rounds\n", outputPath, rounds)
+	}
+	return testCode
+}
+
+// requestRepair asks the repair model to fix the compile errors of testCode
+func (tg *TestGenerator) requestRepair(ctx context.Context, code, testCode, compileOutput string) (string, modelMetrics, error) {
+	resp, err := tg.client.List(ctx)
+	if err != nil {
+		return "", modelMetrics{}, fmt.Errorf("failed to list models: %v", err)
+	}
+	modelsToTry := tg.repairModelList(resp)
+	if len(modelsToTry) == 0 {
+		return "", modelMetrics{}, fmt.Errorf("no repair models available")
+	}
+
+	if len(compileOutput) > maxRepairErrorBytes {
+		compileOutput = compileOutput[:maxRepairErrorBytes] + "\n... (truncated)"
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("The unit test file below does not compile. Fix the compile errors and return the complete corrected test file.\n")
+	prompt.WriteString("Keep every test and its intent; only change what is needed to make it compile.\n\n")
+	prompt.WriteString("Compiler errors:\n")
+	prompt.WriteString(compileOutput)
+	prompt.WriteString("\n\nTest file:\n")
+	prompt.WriteString(testCode)
+	prompt.WriteString("\n\nCode under test:\n")
+	prompt.WriteString(code)
+
+	parts := promptParts{System: tg.systemPrompt(), User: prompt.String()}
+	req := api.GenerateRequest{
+		Prompt:    parts.combined(),
+		Options:   tg.buildModelOptions(),
+		KeepAlive: tg.keepAlive(),
+	}
+	if tg.useChatAPI() {
+		req.System = parts.System
+		req.Prompt = parts.User
+	}
+	log.Printf("Sending repair request with prompt (%d bytes)", len(req.System)+len(req.Prompt))
+
+	return tg.tryModelsWithRetries(ctx, req, modelsToTry, nil)
+}
+
+// repairModelList returns the available models to repair with: the repair model first,
+// falling back to the generation models
+func (tg *TestGenerator) repairModelList(resp *api.ListResponse) []string {
+	models := tg.buildModelList(resp)
+	repairModel := tg.rules.ModelConfig.RepairModel
+	if repairModel == "" {
+		return models
+	}
+
+	for _, model := range resp.Models {
+		if model.Name != repairModel {
+			continue
+		}
+		ordered := []string{repairModel}
+		for _, fallback := range models {
+			if fallback != repairModel {
+				ordered = append(ordered, fallback)
+			}
+		}
+		return ordered
+	}
+	log.Printf("Repair model %s is not available, using the generation models", repairModel)
+	return models
+}
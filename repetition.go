@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// maxRepetitionWindow is the longest block of lines checked for repetition
+const maxRepetitionWindow = 8
+
+// errRepeatingOutput aborts a streaming response that got stuck in a loop
+var errRepeatingOutput = errors.New("model output is repeating")
+
+// repetitionDetector watches a streaming response for a line, or a block of up to
+// maxRepetitionWindow lines, repeated threshold times in a row
+type repetitionDetector struct {
+	threshold int
+	partial   strings.Builder
+	lines     []string
+}
+
+// newRepetitionDetector returns a detector that trips after threshold repetitions,
+// or nil when threshold is below 2 and detection is disabled
+func newRepetitionDetector(threshold int) *repetitionDetector {
+	if threshold < 2 {
+		return nil
+	}
+	return &repetitionDetector{threshold: threshold}
+}
+
+// write feeds a chunk of the response and returns errRepeatingOutput once the output repeats.
+// A nil detector accepts everything.
+func (d *repetitionDetector) write(chunk string) error {
+	if d == nil {
+		return nil
+	}
+
+	for {
+		newline := strings.IndexByte(chunk, '\n')
+		if newline < 0 {
+			d.partial.WriteString(chunk)
+			return nil
+		}
+		d.partial.WriteString(chunk[:newline])
+		chunk = chunk[newline+1:]
+
+		line := strings.TrimSpace(d.partial.String())
+		d.partial.Reset()
+		if line == "" {
+			continue
+		}
+		if block, ok := d.addLine(line); ok {
+			return fmt.Errorf("%w: %q repeated %d times", errRepeatingOutput, block, d.threshold)
+		}
+	}
+}
+
+// addLine records a completed non-blank line and reports the block that is repeating, if any
+func (d *repetitionDetector) addLine(line string) (string, bool) {
+	d.lines = append(d.lines, line)
+	if keep := maxRepetitionWindow * d.threshold; len(d.lines) > keep {
+		d.lines = d.lines[len(d.lines)-keep:]
+	}
+
+	for window := 1; window <= maxRepetitionWindow; window++ {
+		span := window * d.threshold
+		if span > len(d.lines) {
+			break
+		}
+		recent := d.lines[len(d.lines)-span:]
+		block := recent[len(recent)-window:]
+		if !hasWordCharacter(block) {
+			// Runs of closing braces and the like are ordinary code
+			continue
+		}
+		repeated := true
+		for i := 0; i < span-window && repeated; i++ {
+			repeated = recent[i] == block[i%window]
+		}
+		if repeated {
+			return strings.Join(block, " / "), true
+		}
+	}
+	return "", false
+}
+
+// hasWordCharacter reports whether any of the lines contains a letter or digit
+func hasWordCharacter(lines []string) bool {
+	for _, line := range lines {
+		if strings.IndexFunc(line, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultOllamaHost is used when OLLAMA_HOST is not set
+const defaultOllamaHost = "http://localhost:11434"
+
+var (
+	// hostSemaphores bounds concurrent model requests per Ollama host across all generators of
+	// this process
+	hostSemaphores   = make(map[string]chan struct{})
+	hostSemaphoresMu sync.Mutex
+
+	// hostBuckets rate-limits model requests per Ollama host across all generators
+	hostBuckets   = make(map[string]*tokenBucket)
+	hostBucketsMu sync.Mutex
+)
+
+// ollamaHost returns the Ollama server address from OLLAMA_HOST, or the default
+func ollamaHost() string {
+	if host := os.Getenv("OLLAMA_HOST"); host != "" {
+		return host
+	}
+	return defaultOllamaHost
+}
+
+// acquireHostSlot blocks until fewer than limit requests are in flight to host, or ctx is
+// done, and returns the function that releases the slot. The first limit seen for a host wins.
+// The limit only counts the requests of this process; separate utg runs against the same host
+// each get their own limit.
+func acquireHostSlot(ctx context.Context, host string, limit int) (func(), error) {
+	if limit <= 0 {
+		limit = 1
+	}
+
+	hostSemaphoresMu.Lock()
+	semaphore, ok := hostSemaphores[host]
+	if !ok {
+		semaphore = make(chan struct{}, limit)
+		hostSemaphores[host] = semaphore
+	}
+	hostSemaphoresMu.Unlock()
+
+	select {
+	case semaphore <- struct{}{}:
+		return func() { <-semaphore }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// tokenBucket allows ratePerMinute requests per minute on average, with bursts of up to
+// capacity requests after an idle period
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+// newTokenBucket returns a full bucket
+func newTokenBucket(ratePerMinute, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &tokenBucket{
+		tokens:   float64(burst),
+		capacity: float64(burst),
+		rate:     float64(ratePerMinute) / 60,
+		last:     time.Now(),
+	}
+}
+
+// reserve takes a token if one is available, and otherwise returns how long until the next one
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// wait blocks until a token is available or ctx is done
+func (b *tokenBucket) wait(ctx context.Context) error {
+	announced := false
+	for {
+		delay := b.reserve()
+		if delay == 0 {
+			return nil
+		}
+		if !announced {
+			fmt.Printf("⏳ Request rate limit reached, next request in %v\n", delay.Round(time.Second))
+			announced = true
+		}
+		log.Printf("Rate limited, waiting %v for a request token", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// waitForHostRate blocks until another request to host fits in ratePerMinute, with bursts of
+// up to burst requests. A rate of 0 disables the limit; the first rate seen for a host wins.
+func waitForHostRate(ctx context.Context, host string, ratePerMinute, burst int) error {
+	if ratePerMinute <= 0 {
+		return nil
+	}
+
+	hostBucketsMu.Lock()
+	bucket, ok := hostBuckets[host]
+	if !ok {
+		bucket = newTokenBucket(ratePerMinute, burst)
+		hostBuckets[host] = bucket
+	}
+	hostBucketsMu.Unlock()
+
+	return bucket.wait(ctx)
+}
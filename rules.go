@@ -1,11 +1,18 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
+// cppIdentifierPattern matches a plain C++ identifier, as macro names are
+var cppIdentifierPattern = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+
 // Rules defines the configuration structure for unit test generation
 type Rules struct {
 	Language      string `yaml:"language"`
@@ -15,31 +22,53 @@ type Rules struct {
 		TestPrefix             string `yaml:"test_prefix"`
 		DescriptiveTestNames   bool   `yaml:"descriptive_test_names"`
 		IncludeClassInTestName bool   `yaml:"include_class_in_test_name"`
+		DisambiguateOverloads  bool   `yaml:"disambiguate_overloads"`
 	} `yaml:"naming"`
 	Includes  []string `yaml:"includes"`
 	Standards struct {
-		CPPStandard string `yaml:"cpp_standard"`
+		CPPStandard  string   `yaml:"cpp_standard"`
+		CPPStandards []string `yaml:"cpp_standards"`
 	} `yaml:"standards"`
 	TestCaseRules struct {
-		PerMethod       int      `yaml:"per_method"`
-		TotalTests      int      `yaml:"total_tests"`
-		IncludePositive bool     `yaml:"include_positive_case"`
-		IncludeNegative bool     `yaml:"include_negative_case"`
-		AvoidEdgeCases  []string `yaml:"avoid_edge_cases"`
+		PerMethod          int            `yaml:"per_method"`
+		TotalTests         int            `yaml:"total_tests"`
+		IncludePositive    bool           `yaml:"include_positive_case"`
+		IncludeNegative    bool           `yaml:"include_negative_case"`
+		AvoidEdgeCases     []string       `yaml:"avoid_edge_cases"`
+		PerMethodOverrides map[string]int `yaml:"per_method_overrides"`
+		TestExceptions     bool           `yaml:"test_exceptions"`
+		PropertyBased      bool           `yaml:"property_based"`
+		SampleDataFile     string         `yaml:"sample_data_file"`
+		DeclarationOnly    string         `yaml:"declaration_only"`
 	} `yaml:"test_case_rules"`
 	Assertions struct {
 		Preferred              []string `yaml:"preferred"`
 		CompleteBracesRequired bool     `yaml:"complete_braces_required"`
+		DefaultSeverity        string   `yaml:"default_severity"`
+		FatalPreconditions     bool     `yaml:"fatal_preconditions"`
+		UseMatchers            bool     `yaml:"use_matchers"`
 	} `yaml:"assertions"`
 	MethodsToTest struct {
-		Source     string   `yaml:"source"`
-		ManualList []string `yaml:"manual_list"`
+		Source       string   `yaml:"source"`
+		ManualList   []string `yaml:"manual_list"`
+		AccessLevels []string `yaml:"access_levels"`
 	} `yaml:"methods_to_test"`
 	OutputFormat struct {
-		FileType           string `yaml:"file_type"`
-		MarkdownCodeFences bool   `yaml:"markdown_code_fences"`
-		ExtraText          bool   `yaml:"extra_text"`
-		ExampleInPrompt    bool   `yaml:"example_in_prompt"`
+		FileType             string   `yaml:"file_type"`
+		MarkdownCodeFences   bool     `yaml:"markdown_code_fences"`
+		ExtraText            bool     `yaml:"extra_text"`
+		ExampleInPrompt      bool     `yaml:"example_in_prompt"`
+		MinBytes             int      `yaml:"min_bytes"`
+		MaxBytes             int      `yaml:"max_bytes"`
+		MinLines             int      `yaml:"min_lines"`
+		MaxLines             int      `yaml:"max_lines"`
+		LineEndings          string   `yaml:"line_endings"`
+		TraceabilityComments bool     `yaml:"traceability_comments"`
+		ExampleSource        string   `yaml:"example_source"`
+		ExampleTest          string   `yaml:"example_test"`
+		Validators           []string `yaml:"validators"`
+		Tautologies          string   `yaml:"tautologies"`
+		StrictValidation     bool     `yaml:"strict_validation"`
 	} `yaml:"output_format"`
 	LLMPromptGuidance struct {
 		RoleDescription       string `yaml:"role_description"`
@@ -47,28 +76,155 @@ type Rules struct {
 		ExampleFormatIncluded bool   `yaml:"example_format_included"`
 		CodeToTestInPrompt    bool   `yaml:"code_to_test_in_prompt"`
 		AvoidCommentsOutside  bool   `yaml:"avoid_comments_outside_code"`
+		IncludeImplementation bool   `yaml:"include_implementation"`
+		CommentMarker         string `yaml:"comment_marker"`
+		Language              string `yaml:"language"`
+		StripComments         bool   `yaml:"strip_comments"`
+		MaxCodeBytes          int    `yaml:"max_code_bytes"`
+		IncludeDepth          int    `yaml:"include_depth"`
+		ExistingTests         bool   `yaml:"existing_tests"`
 	} `yaml:"llm_prompt_guidance"`
 	Coverage struct {
-		MinimumThreshold float64 `yaml:"minimum_threshold"`
-		Enabled          bool    `yaml:"enabled"`
+		MinimumThreshold  float64  `yaml:"minimum_threshold"`
+		Enabled           bool     `yaml:"enabled"`
+		HTML              bool     `yaml:"html"`
+		SkipCovered       bool     `yaml:"skip_covered"`
+		TodoList          bool     `yaml:"todo_list"`
+		Accumulate        bool     `yaml:"accumulate"`
+		Badge             bool     `yaml:"badge"`
+		DirectoryDepth    int      `yaml:"directory_depth"`
+		ImprovementRounds int      `yaml:"improvement_rounds"`
+		ExcludeFunctions  []string `yaml:"exclude_functions"`
 	} `yaml:"coverage"`
 	ModelConfig struct {
-		PrimaryModel   string   `yaml:"primary_model"`
-		FallbackModels []string `yaml:"fallback_models"`
-		MaxRetries     int      `yaml:"max_retries"`
-		TimeoutMinutes int      `yaml:"timeout_minutes"`
+		PrimaryModel          string   `yaml:"primary_model"`
+		FallbackModels        []string `yaml:"fallback_models"`
+		RepairModel           string   `yaml:"repair_model"`
+		RepairRounds          int      `yaml:"repair_rounds"`
+		MaxRetries            int      `yaml:"max_retries"`
+		TimeoutMinutes        int      `yaml:"timeout_minutes"`
+		MaxRetryDelay         int      `yaml:"max_retry_delay"`
+		RetryableErrors       []string `yaml:"retryable_errors"`
+		MaxConcurrentRequests int      `yaml:"max_concurrent_requests"`
+		RequestsPerMinute     int      `yaml:"requests_per_minute"`
+		RequestBurst          int      `yaml:"request_burst"`
+		FileWorkers           int      `yaml:"file_workers"`
+		APIMode               string   `yaml:"api_mode"`
+		KeepAlive             string   `yaml:"keep_alive"`
+		RepetitionThreshold   int      `yaml:"repetition_threshold"`
+		Options               struct {
+			Seed        *int     `yaml:"seed"`
+			Temperature *float64 `yaml:"temperature"`
+		} `yaml:"options"`
 	} `yaml:"model_config"`
 	Paths struct {
-		CodebaseDir   string   `yaml:"codebase_dir"`
-		TestsDir      string   `yaml:"tests_dir"`
-		TempDir       string   `yaml:"temp_dir"`
-		FoldersToScan []string `yaml:"folders_to_scan"`
+		CodebaseDir      string   `yaml:"codebase_dir"`
+		TestsDir         string   `yaml:"tests_dir"`
+		FallbackTestsDir string   `yaml:"fallback_tests_dir"`
+		TestDirs         []string `yaml:"test_dirs"`
+		TempDir          string   `yaml:"temp_dir"`
+		FoldersToScan    []string `yaml:"folders_to_scan"`
+		FollowSymlinks   bool     `yaml:"follow_symlinks"`
+		ContextHeaders   []string `yaml:"context_headers"`
+		ReadWorkers      int      `yaml:"read_workers"`
 	} `yaml:"paths"`
+	Build struct {
+		Concurrency           int      `yaml:"concurrency"`
+		CompileMemoryMB       int      `yaml:"compile_memory_mb"`
+		MemoryBudgetMB        int      `yaml:"memory_budget_mb"`
+		BuildDir              string   `yaml:"build_dir"`
+		LinkLibraries         []string `yaml:"link_libraries"`
+		LibraryHeadersDir     string   `yaml:"library_headers_dir"`
+		Repeat                int      `yaml:"repeat"`
+		ExtraSources          []string `yaml:"extra_sources"`
+		NoCoverage            bool     `yaml:"no_coverage"`
+		UsePCH                bool     `yaml:"use_pch"`
+		AutoFetchGTest        bool     `yaml:"auto_fetch_gtest"`
+		GTestIncludeDir       string   `yaml:"gtest_include_dir"`
+		GMockIncludeDir       string   `yaml:"gmock_include_dir"`
+		GTestLibDir           string   `yaml:"gtest_lib_dir"`
+		RapidCheckDir         string   `yaml:"rapidcheck_dir"`
+		FrameworkCompileFlags []string `yaml:"framework_compile_flags"`
+		FrameworkLinkFlags    []string `yaml:"framework_link_flags"`
+	} `yaml:"build"`
+	Mocks struct {
+		StubsDir    string   `yaml:"stubs_dir"`
+		GlobalStubs []string `yaml:"global_stubs"`
+		MockTime    bool     `yaml:"mock_time"`
+	} `yaml:"mocks"`
+	LanguageProfiles map[string]LanguageProfile `yaml:"language_profiles"`
+	Fixtures         struct {
+		SharedHeader string            `yaml:"shared_header"`
+		Template     string            `yaml:"template"`
+		Construction map[string]string `yaml:"construction"`
+	} `yaml:"fixtures"`
+	Output struct {
+		GitCommit struct {
+			Enabled bool   `yaml:"enabled"`
+			Branch  string `yaml:"branch"`
+			Message string `yaml:"message"`
+		} `yaml:"git_commit"`
+		EmitCompileDB     bool   `yaml:"emit_compile_db"`
+		GenerateMakefile  bool   `yaml:"generate_makefile"`
+		Banner            string `yaml:"banner"`
+		EmptyTests        string `yaml:"empty_tests"`
+		RegistrationMacro struct {
+			Name  string `yaml:"name"`
+			Usage string `yaml:"usage"`
+		} `yaml:"registration_macro"`
+	} `yaml:"output"`
+}
+
+// LanguageProfile describes how sources with a given file extension are prompted for and compiled
+type LanguageProfile struct {
+	Language string `yaml:"language"` // language named in the prompt, e.g. "C" or "C++"
+	Compiler string `yaml:"compiler"` // compiler for the sources, e.g. "gcc"
+	Standard string `yaml:"standard"` // language standard passed to the compiler, e.g. "c11"
+}
+
+// DefaultRetryableErrors lists error substrings that indicate a transient failure worth retrying
+var DefaultRetryableErrors = []string{
+	"model is loading",
+	"connection reset",
+	"connection refused",
+	"context deadline exceeded",
+	"timeout",
+	"EOF",
+	"server busy",
+	"empty response",
+	"does not contain valid C++ code",
+	"response too short",
+}
+
+// UsesPrebuiltLibraries reports whether tests link against prebuilt libraries instead of compiling sources
+func (r *Rules) UsesPrebuiltLibraries() bool {
+	return len(r.Build.LinkLibraries) > 0
+}
+
+// ScannedDir returns the directory tests are generated from: the library headers directory when
+// testing prebuilt libraries, CodebaseDir otherwise
+func (r *Rules) ScannedDir() string {
+	if r.UsesPrebuiltLibraries() && r.Build.LibraryHeadersDir != "" {
+		return r.Build.LibraryHeadersDir
+	}
+	return r.Paths.CodebaseDir
+}
+
+// TestSearchDirs returns the directories the test runner discovers tests in: TestsDir, where
+// tests are generated, followed by the additional TestDirs
+func (r *Rules) TestSearchDirs() []string {
+	dirs := []string{r.Paths.TestsDir}
+	for _, dir := range r.Paths.TestDirs {
+		if dir != "" && filepath.Clean(dir) != filepath.Clean(r.Paths.TestsDir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
 }
 
-// LoadRules loads configuration from a YAML file
+// LoadRules loads configuration from a YAML file or an http(s) URL
 func LoadRules(filePath string) (*Rules, error) {
-	data, err := os.ReadFile(filePath)
+	data, err := readRulesSource(filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -78,6 +234,21 @@ func LoadRules(filePath string) (*Rules, error) {
 	if err != nil {
 		return nil, err
 	}
+	if _, err := parseKeepAlive(rules.ModelConfig.KeepAlive); err != nil {
+		return nil, fmt.Errorf("invalid model_config.keep_alive: %v", err)
+	}
+	// Half a system install would compile against its headers but build and link the vendored copy
+	if (rules.Build.GTestIncludeDir == "") != (rules.Build.GTestLibDir == "") {
+		return nil, fmt.Errorf("build.gtest_include_dir and build.gtest_lib_dir must be set together to use a system Google Test install")
+	}
+	if name := strings.TrimSpace(rules.Output.RegistrationMacro.Name); name != "" && !cppIdentifierPattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid output.registration_macro.name: %q is not a C++ identifier", name)
+	}
+	switch strings.ToLower(strings.TrimSpace(rules.ModelConfig.APIMode)) {
+	case "", "generate", "chat":
+	default:
+		return nil, fmt.Errorf("invalid model_config.api_mode: %q is neither generate nor chat", rules.ModelConfig.APIMode)
+	}
 
 	return &rules, nil
 }
@@ -104,10 +275,12 @@ func GetDefaultRules() *Rules {
 			TestPrefix             string `yaml:"test_prefix"`
 			DescriptiveTestNames   bool   `yaml:"descriptive_test_names"`
 			IncludeClassInTestName bool   `yaml:"include_class_in_test_name"`
+			DisambiguateOverloads  bool   `yaml:"disambiguate_overloads"`
 		}{
 			TestPrefix:             "TEST",
 			DescriptiveTestNames:   true,
 			IncludeClassInTestName: true,
+			DisambiguateOverloads:  true,
 		},
 		Includes: []string{
 			"#include <gtest/gtest.h>",
@@ -116,47 +289,77 @@ func GetDefaultRules() *Rules {
 			"#include \"example.h\"",
 		},
 		Standards: struct {
-			CPPStandard string `yaml:"cpp_standard"`
+			CPPStandard  string   `yaml:"cpp_standard"`
+			CPPStandards []string `yaml:"cpp_standards"`
 		}{
 			CPPStandard: "C++17",
 		},
 		TestCaseRules: struct {
-			PerMethod       int      `yaml:"per_method"`
-			TotalTests      int      `yaml:"total_tests"`
-			IncludePositive bool     `yaml:"include_positive_case"`
-			IncludeNegative bool     `yaml:"include_negative_case"`
-			AvoidEdgeCases  []string `yaml:"avoid_edge_cases"`
+			PerMethod          int            `yaml:"per_method"`
+			TotalTests         int            `yaml:"total_tests"`
+			IncludePositive    bool           `yaml:"include_positive_case"`
+			IncludeNegative    bool           `yaml:"include_negative_case"`
+			AvoidEdgeCases     []string       `yaml:"avoid_edge_cases"`
+			PerMethodOverrides map[string]int `yaml:"per_method_overrides"`
+			TestExceptions     bool           `yaml:"test_exceptions"`
+			PropertyBased      bool           `yaml:"property_based"`
+			SampleDataFile     string         `yaml:"sample_data_file"`
+			DeclarationOnly    string         `yaml:"declaration_only"`
 		}{
 			PerMethod:       2,
 			TotalTests:      4,
 			IncludePositive: true,
 			IncludeNegative: true,
 			AvoidEdgeCases:  []string{"INT_MIN", "INT_MAX"},
+			TestExceptions:  true,
 		},
 		Assertions: struct {
 			Preferred              []string `yaml:"preferred"`
 			CompleteBracesRequired bool     `yaml:"complete_braces_required"`
+			DefaultSeverity        string   `yaml:"default_severity"`
+			FatalPreconditions     bool     `yaml:"fatal_preconditions"`
+			UseMatchers            bool     `yaml:"use_matchers"`
 		}{
 			Preferred:              []string{"EXPECT_EQ", "EXPECT_NE", "EXPECT_TRUE", "EXPECT_FALSE"},
 			CompleteBracesRequired: true,
+			DefaultSeverity:        "",
+			FatalPreconditions:     false,
 		},
 		MethodsToTest: struct {
-			Source     string   `yaml:"source"`
-			ManualList []string `yaml:"manual_list"`
+			Source       string   `yaml:"source"`
+			ManualList   []string `yaml:"manual_list"`
+			AccessLevels []string `yaml:"access_levels"`
 		}{
-			Source:     "manual",
-			ManualList: []string{"add", "subtract"},
+			Source:       "manual",
+			ManualList:   []string{"add", "subtract"},
+			AccessLevels: []string{"public"},
 		},
 		OutputFormat: struct {
-			FileType           string `yaml:"file_type"`
-			MarkdownCodeFences bool   `yaml:"markdown_code_fences"`
-			ExtraText          bool   `yaml:"extra_text"`
-			ExampleInPrompt    bool   `yaml:"example_in_prompt"`
+			FileType             string   `yaml:"file_type"`
+			MarkdownCodeFences   bool     `yaml:"markdown_code_fences"`
+			ExtraText            bool     `yaml:"extra_text"`
+			ExampleInPrompt      bool     `yaml:"example_in_prompt"`
+			MinBytes             int      `yaml:"min_bytes"`
+			MaxBytes             int      `yaml:"max_bytes"`
+			MinLines             int      `yaml:"min_lines"`
+			MaxLines             int      `yaml:"max_lines"`
+			LineEndings          string   `yaml:"line_endings"`
+			TraceabilityComments bool     `yaml:"traceability_comments"`
+			ExampleSource        string   `yaml:"example_source"`
+			ExampleTest          string   `yaml:"example_test"`
+			Validators           []string `yaml:"validators"`
+			Tautologies          string   `yaml:"tautologies"`
+			StrictValidation     bool     `yaml:"strict_validation"`
 		}{
-			FileType:           "cpp",
-			MarkdownCodeFences: false,
-			ExtraText:          false,
-			ExampleInPrompt:    true,
+			FileType:             "cpp",
+			MarkdownCodeFences:   false,
+			ExtraText:            false,
+			ExampleInPrompt:      true,
+			LineEndings:          "",
+			TraceabilityComments: false,
+			ExampleSource:        "",
+			ExampleTest:          "",
+			Tautologies:          "off",
 		},
 		LLMPromptGuidance: struct {
 			RoleDescription       string `yaml:"role_description"`
@@ -164,40 +367,155 @@ func GetDefaultRules() *Rules {
 			ExampleFormatIncluded bool   `yaml:"example_format_included"`
 			CodeToTestInPrompt    bool   `yaml:"code_to_test_in_prompt"`
 			AvoidCommentsOutside  bool   `yaml:"avoid_comments_outside_code"`
+			IncludeImplementation bool   `yaml:"include_implementation"`
+			CommentMarker         string `yaml:"comment_marker"`
+			Language              string `yaml:"language"`
+			StripComments         bool   `yaml:"strip_comments"`
+			MaxCodeBytes          int    `yaml:"max_code_bytes"`
+			IncludeDepth          int    `yaml:"include_depth"`
+			ExistingTests         bool   `yaml:"existing_tests"`
 		}{
 			RoleDescription:       "You are an expert C++ programmer tasked with generating unit tests using Google Test for the provided C++ code. Follow these requirements strictly:",
 			StrictFormatting:      true,
 			ExampleFormatIncluded: true,
 			CodeToTestInPrompt:    true,
 			AvoidCommentsOutside:  true,
+			IncludeImplementation: false,
+			CommentMarker:         "@utg:",
+			Language:              "en",
 		},
 		Coverage: struct {
-			MinimumThreshold float64 `yaml:"minimum_threshold"`
-			Enabled          bool    `yaml:"enabled"`
+			MinimumThreshold  float64  `yaml:"minimum_threshold"`
+			Enabled           bool     `yaml:"enabled"`
+			HTML              bool     `yaml:"html"`
+			SkipCovered       bool     `yaml:"skip_covered"`
+			TodoList          bool     `yaml:"todo_list"`
+			Accumulate        bool     `yaml:"accumulate"`
+			Badge             bool     `yaml:"badge"`
+			DirectoryDepth    int      `yaml:"directory_depth"`
+			ImprovementRounds int      `yaml:"improvement_rounds"`
+			ExcludeFunctions  []string `yaml:"exclude_functions"`
 		}{
-			MinimumThreshold: 80.0,
-			Enabled:          true,
+			MinimumThreshold:  80.0,
+			Enabled:           true,
+			HTML:              false,
+			SkipCovered:       false,
+			TodoList:          false,
+			ImprovementRounds: 0,
 		},
 		ModelConfig: struct {
-			PrimaryModel   string   `yaml:"primary_model"`
-			FallbackModels []string `yaml:"fallback_models"`
-			MaxRetries     int      `yaml:"max_retries"`
-			TimeoutMinutes int      `yaml:"timeout_minutes"`
+			PrimaryModel          string   `yaml:"primary_model"`
+			FallbackModels        []string `yaml:"fallback_models"`
+			RepairModel           string   `yaml:"repair_model"`
+			RepairRounds          int      `yaml:"repair_rounds"`
+			MaxRetries            int      `yaml:"max_retries"`
+			TimeoutMinutes        int      `yaml:"timeout_minutes"`
+			MaxRetryDelay         int      `yaml:"max_retry_delay"`
+			RetryableErrors       []string `yaml:"retryable_errors"`
+			MaxConcurrentRequests int      `yaml:"max_concurrent_requests"`
+			RequestsPerMinute     int      `yaml:"requests_per_minute"`
+			RequestBurst          int      `yaml:"request_burst"`
+			FileWorkers           int      `yaml:"file_workers"`
+			APIMode               string   `yaml:"api_mode"`
+			KeepAlive             string   `yaml:"keep_alive"`
+			RepetitionThreshold   int      `yaml:"repetition_threshold"`
+			Options               struct {
+				Seed        *int     `yaml:"seed"`
+				Temperature *float64 `yaml:"temperature"`
+			} `yaml:"options"`
 		}{
-			PrimaryModel:   "qwen2.5-coder:7b",
-			FallbackModels: []string{},
-			MaxRetries:     3,
-			TimeoutMinutes: 5,
+			PrimaryModel:          "qwen2.5-coder:7b",
+			FallbackModels:        []string{},
+			MaxRetries:            3,
+			TimeoutMinutes:        5,
+			MaxRetryDelay:         30,
+			RetryableErrors:       DefaultRetryableErrors,
+			MaxConcurrentRequests: 1,
+			FileWorkers:           1,
+			APIMode:               "generate",
+			RepairModel:           "",
+			RepairRounds:          0,
 		},
 		Paths: struct {
-			CodebaseDir   string   `yaml:"codebase_dir"`
-			TestsDir      string   `yaml:"tests_dir"`
-			TempDir       string   `yaml:"temp_dir"`
-			FoldersToScan []string `yaml:"folders_to_scan"`
+			CodebaseDir      string   `yaml:"codebase_dir"`
+			TestsDir         string   `yaml:"tests_dir"`
+			FallbackTestsDir string   `yaml:"fallback_tests_dir"`
+			TestDirs         []string `yaml:"test_dirs"`
+			TempDir          string   `yaml:"temp_dir"`
+			FoldersToScan    []string `yaml:"folders_to_scan"`
+			FollowSymlinks   bool     `yaml:"follow_symlinks"`
+			ContextHeaders   []string `yaml:"context_headers"`
+			ReadWorkers      int      `yaml:"read_workers"`
+		}{
+			CodebaseDir:    "./codebase",
+			TestsDir:       "./tests",
+			TempDir:        "",
+			FollowSymlinks: false,
+			ReadWorkers:    0,
+		},
+		Build: struct {
+			Concurrency           int      `yaml:"concurrency"`
+			CompileMemoryMB       int      `yaml:"compile_memory_mb"`
+			MemoryBudgetMB        int      `yaml:"memory_budget_mb"`
+			BuildDir              string   `yaml:"build_dir"`
+			LinkLibraries         []string `yaml:"link_libraries"`
+			LibraryHeadersDir     string   `yaml:"library_headers_dir"`
+			Repeat                int      `yaml:"repeat"`
+			ExtraSources          []string `yaml:"extra_sources"`
+			NoCoverage            bool     `yaml:"no_coverage"`
+			UsePCH                bool     `yaml:"use_pch"`
+			AutoFetchGTest        bool     `yaml:"auto_fetch_gtest"`
+			GTestIncludeDir       string   `yaml:"gtest_include_dir"`
+			GMockIncludeDir       string   `yaml:"gmock_include_dir"`
+			GTestLibDir           string   `yaml:"gtest_lib_dir"`
+			RapidCheckDir         string   `yaml:"rapidcheck_dir"`
+			FrameworkCompileFlags []string `yaml:"framework_compile_flags"`
+			FrameworkLinkFlags    []string `yaml:"framework_link_flags"`
+		}{
+			Concurrency:       0,
+			BuildDir:          "build",
+			LibraryHeadersDir: "",
+			Repeat:            1,
+			AutoFetchGTest:    false,
+			GTestIncludeDir:   "",
+			GMockIncludeDir:   "",
+			GTestLibDir:       "",
+			RapidCheckDir:     "",
+		},
+		Mocks: struct {
+			StubsDir    string   `yaml:"stubs_dir"`
+			GlobalStubs []string `yaml:"global_stubs"`
+			MockTime    bool     `yaml:"mock_time"`
+		}{
+			StubsDir: "",
+		},
+		Fixtures: struct {
+			SharedHeader string            `yaml:"shared_header"`
+			Template     string            `yaml:"template"`
+			Construction map[string]string `yaml:"construction"`
+		}{
+			SharedHeader: "",
+			Template:     "",
+		},
+		Output: struct {
+			GitCommit struct {
+				Enabled bool   `yaml:"enabled"`
+				Branch  string `yaml:"branch"`
+				Message string `yaml:"message"`
+			} `yaml:"git_commit"`
+			EmitCompileDB     bool   `yaml:"emit_compile_db"`
+			GenerateMakefile  bool   `yaml:"generate_makefile"`
+			Banner            string `yaml:"banner"`
+			EmptyTests        string `yaml:"empty_tests"`
+			RegistrationMacro struct {
+				Name  string `yaml:"name"`
+				Usage string `yaml:"usage"`
+			} `yaml:"registration_macro"`
 		}{
-			CodebaseDir: "./codebase",
-			TestsDir:    "./tests",
-			TempDir:     "",
+			EmitCompileDB:    false,
+			GenerateMakefile: false,
+			Banner:           "",
+			EmptyTests:       "fail",
 		},
 	}
 }
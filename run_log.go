@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runLogFile is the JSON log of the last run, kept in the temp directory
+const runLogFile = "utg-last-run.json"
+
+// runLog is the persisted summary of the last generation run and the last test run
+type runLog struct {
+	Generation *generationRunLog `json:"generation,omitempty"`
+	Tests      *testRunLog       `json:"tests,omitempty"`
+}
+
+// generationRunLog summarizes a test generation run
+type generationRunLog struct {
+	Time      time.Time            `json:"time"`
+	Seconds   float64              `json:"seconds"`
+	Groups    int                  `json:"groups"`
+	Generated int                  `json:"generated"`
+	Skipped   []string             `json:"skipped,omitempty"`
+	Failed    []groupFailureRecord `json:"failed,omitempty"`
+	Aborted   bool                 `json:"aborted,omitempty"`
+}
+
+// groupFailureRecord is a failed group in the run log
+type groupFailureRecord struct {
+	Group string `json:"group"`
+	Error string `json:"error"`
+}
+
+// testRunLog summarizes a run of compiled tests and the coverage they reached
+type testRunLog struct {
+	Time           time.Time       `json:"time"`
+	Tests          []testRunRecord `json:"tests"`
+	CompileSeconds float64         `json:"compile_seconds"`
+	RunSeconds     float64         `json:"run_seconds"`
+	CoveredLines   int             `json:"covered_lines"`
+	TotalLines     int             `json:"total_lines"`
+}
+
+// testRunRecord is the outcome and timing of one test file in the run log
+type testRunRecord struct {
+	TestFile       string  `json:"test_file"`
+	Status         string  `json:"status"`
+	CompileSeconds float64 `json:"compile_seconds"`
+	RunSeconds     float64 `json:"run_seconds"`
+	Cases          int     `json:"cases,omitempty"`
+	CasesPassed    int     `json:"cases_passed,omitempty"`
+	CasesFailed    int     `json:"cases_failed,omitempty"`
+	CasesSkipped   int     `json:"cases_skipped,omitempty"`
+}
+
+// newTestRunLog builds the run log of a set of test results
+func newTestRunLog(results []batchTestResult) *testRunLog {
+	log := &testRunLog{Time: time.Now()}
+	for _, result := range results {
+		log.Tests = append(log.Tests, testRunRecord{
+			TestFile:       result.TestFile,
+			Status:         result.status(),
+			CompileSeconds: result.CompileTime.Seconds(),
+			RunSeconds:     result.Run.Duration.Seconds(),
+			Cases:          result.Run.Counts.Total,
+			CasesPassed:    result.Run.Counts.Passed,
+			CasesFailed:    result.Run.Counts.Failed,
+			CasesSkipped:   result.Run.Counts.Skipped,
+		})
+		log.CompileSeconds += result.CompileTime.Seconds()
+		log.RunSeconds += result.Run.Duration.Seconds()
+	}
+	return log
+}
+
+// runLogPath returns where the run log is written
+func runLogPath(rules *Rules) string {
+	baseDir := rules.Paths.TempDir
+	if baseDir == "" {
+		baseDir = os.TempDir()
+	}
+	return filepath.Join(baseDir, runLogFile)
+}
+
+// readRunLog loads the persisted run log
+func readRunLog(rules *Rules) (runLog, error) {
+	var log runLog
+	data, err := os.ReadFile(runLogPath(rules))
+	if err != nil {
+		return log, err
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		return log, fmt.Errorf("failed to parse run log %s: %v", runLogPath(rules), err)
+	}
+	return log, nil
+}
+
+// writeRunLog persists the run log as JSON
+func writeRunLog(log runLog, rules *Rules) error {
+	path := runLogPath(rules)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for run log: %v", err)
+	}
+
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run log: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run log %s: %v", path, err)
+	}
+	return nil
+}
+
+// updateRunLog replaces one section of the persisted run log, keeping the other
+func updateRunLog(rules *Rules, update func(*runLog)) {
+	log, err := readRunLog(rules)
+	if err != nil && !os.IsNotExist(err) {
+		log = runLog{}
+	}
+	update(&log)
+	if err := writeRunLog(log, rules); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+}
+
+// recordTestRun stores a test run in the run log
+func recordTestRun(tests *testRunLog, rules *Rules) {
+	updateRunLog(rules, func(log *runLog) { log.Tests = tests })
+}
+
+// recordGenerationRun stores a generation run in the run log
+func recordGenerationRun(generation *generationRunLog, rules *Rules) {
+	updateRunLog(rules, func(log *runLog) { log.Generation = generation })
+}
+
+// PrintLastRun reprints the summary of the last generation and test runs from the run log
+func PrintLastRun(rules *Rules) error {
+	log, err := readRunLog(rules)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no run log found at %s", runLogPath(rules))
+	}
+	if err != nil {
+		return err
+	}
+
+	if gen := log.Generation; gen != nil {
+		fmt.Printf("📝 Last generation run: %s (%v)\n", gen.Time.Format(time.RFC1123), secondsDuration(gen.Seconds))
+		fmt.Printf("   %d groups: %d generated, %d failed, %d skipped\n", gen.Groups, gen.Generated, len(gen.Failed), len(gen.Skipped))
+		if gen.Aborted {
+			fmt.Println("   🛑 The run was aborted")
+		}
+		for _, failure := range gen.Failed {
+			fmt.Printf("   ❌ %s: %s\n", failure.Group, failure.Error)
+		}
+		for _, group := range gen.Skipped {
+			fmt.Printf("   ⏭️  %s\n", group)
+		}
+	}
+
+	if tests := log.Tests; tests != nil {
+		counts := make(map[string]int)
+		var cases testCounts
+		for _, test := range tests.Tests {
+			counts[test.Status]++
+			cases.add(testCounts{Found: test.Cases > 0, Total: test.Cases, Passed: test.CasesPassed,
+				Failed: test.CasesFailed, Skipped: test.CasesSkipped})
+		}
+		fmt.Printf("🧪 Last test run: %s\n", tests.Time.Format(time.RFC1123))
+		fmt.Printf("   %d passed, %d failed, %d crashed, %d failed to compile\n",
+			counts["passed"], counts["failed"], counts["crashed"], counts["compile_failed"])
+		if cases.Found {
+			fmt.Printf("   🧮 Test cases: %s\n", cases)
+		}
+		fmt.Printf("   ⏱️  Compile time %v, run time %v in total\n", secondsDuration(tests.CompileSeconds), secondsDuration(tests.RunSeconds))
+		if tests.TotalLines > 0 {
+			fmt.Printf("   📊 Coverage: %.2f%% (%d of %d lines)\n",
+				float64(tests.CoveredLines)/float64(tests.TotalLines)*100, tests.CoveredLines, tests.TotalLines)
+		}
+		for _, test := range tests.Tests {
+			if test.Status != "passed" {
+				fmt.Printf("   ❌ %s: %s\n", test.TestFile, test.Status)
+			}
+		}
+	}
+
+	if log.Generation == nil && log.Tests == nil {
+		fmt.Println("ℹ️  The run log is empty")
+	}
+	return nil
+}
+
+// secondsDuration converts seconds from the run log back to a printable duration
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+}
+
+// printTimingSummary reports the total compile and run time of a batch and its slowest compile
+func printTimingSummary(results []batchTestResult) {
+	var compileTime, runTime time.Duration
+	var slowest batchTestResult
+	for _, result := range results {
+		compileTime += result.CompileTime
+		runTime += result.Run.Duration
+		if result.CompileTime > slowest.CompileTime {
+			slowest = result
+		}
+	}
+
+	fmt.Printf("⏱️  Compile time %v, run time %v in total", compileTime.Round(time.Millisecond), runTime.Round(time.Millisecond))
+	if slowest.TestFile != "" {
+		fmt.Printf("; slowest compile: %s (%v)", slowest.TestFile, slowest.CompileTime.Round(time.Millisecond))
+	}
+	fmt.Println()
+}
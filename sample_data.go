@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxSampleDataRows bounds the examples of one method that go into a prompt
+const maxSampleDataRows = 10
+
+// loadSampleData reads TestCaseRules.SampleDataFile once per run and returns its example
+// inputs keyed by method name or pattern. The file is loaded on first use, so every group of
+// the run shares it.
+func (tg *TestGenerator) loadSampleData() (map[string][]string, error) {
+	tg.sampleDataOnce.Do(func() {
+		path := tg.rules.TestCaseRules.SampleDataFile
+		if path == "" {
+			return
+		}
+		tg.sampleData, tg.sampleDataErr = readSampleData(path)
+	})
+	return tg.sampleData, tg.sampleDataErr
+}
+
+// readSampleData parses a sample data file by its extension:
+//   - .json: an object mapping each method to a list of examples, e.g.
+//     {"parseDate": ["2024-02-29", "1999-12-31"], "Vector::scale": [{"factor": 2}]}
+//   - .csv: one example per row, the method in the first column and its inputs in the
+//     others, named by the header row
+func readSampleData(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sample data file: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return parseJSONSampleData(data)
+	case ".csv":
+		return parseCSVSampleData(data)
+	default:
+		return nil, fmt.Errorf("unsupported sample data file %s, use .json or .csv", path)
+	}
+}
+
+// parseJSONSampleData renders every example of a JSON sample data file as compact JSON
+func parseJSONSampleData(data []byte) (map[string][]string, error) {
+	var raw map[string][]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse sample data: %v", err)
+	}
+
+	samples := make(map[string][]string)
+	for method, examples := range raw {
+		for _, example := range examples {
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, example); err != nil {
+				return nil, fmt.Errorf("failed to parse sample data for %s: %v", method, err)
+			}
+			samples[method] = append(samples[method], compact.String())
+		}
+	}
+	return samples, nil
+}
+
+// parseCSVSampleData renders every row of a CSV sample data file as name=value pairs
+func parseCSVSampleData(data []byte) (map[string][]string, error) {
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sample data: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	samples := make(map[string][]string)
+	for _, record := range records[1:] {
+		if len(record) < 2 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		var values []string
+		for i, value := range record[1:] {
+			value = strings.TrimSpace(value)
+			if i+1 < len(header) && header[i+1] != "" {
+				value = header[i+1] + "=" + value
+			}
+			values = append(values, value)
+		}
+		method := strings.TrimSpace(record[0])
+		samples[method] = append(samples[method], strings.Join(values, ", "))
+	}
+	return samples, nil
+}
+
+// sampleDataGuidance returns the prompt lines with the sample inputs of the code's methods.
+// Methods are matched by qualified or short name, and entries may use glob patterns.
+func (tg *TestGenerator) sampleDataGuidance(info SourceInfo) string {
+	samples, err := tg.loadSampleData()
+	if err != nil || len(samples) == 0 {
+		return ""
+	}
+
+	patterns := make([]string, 0, len(samples))
+	for pattern := range samples {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	names := append([]string{}, info.FreeFunctions...)
+	for _, function := range info.Functions {
+		names = append(names, function.Name)
+	}
+
+	var lines []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		shortName := name[strings.LastIndex(name, ":")+1:]
+		var examples []string
+		for _, pattern := range patterns {
+			if matchGlob(pattern, name) || matchGlob(pattern, shortName) {
+				examples = append(examples, samples[pattern]...)
+			}
+		}
+		if len(examples) == 0 {
+			continue
+		}
+		if len(examples) > maxSampleDataRows {
+			examples = examples[:maxSampleDataRows]
+		}
+		lines = append(lines, fmt.Sprintf("  - %s: %s\n", name, strings.Join(examples, "; ")))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "- Use these realistic example inputs from the project's sample data instead of inventing values, " +
+		"and derive the expected results from the code:\n" + strings.Join(lines, "")
+}
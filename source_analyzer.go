@@ -0,0 +1,680 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Matches the start of a class/struct/union definition and captures its name
+	classDefPattern = regexp.MustCompile(`\b(?:class|struct|union)\s+(?:alignas\s*\([^)]*\)\s*)?([A-Za-z_]\w*)[^(]*$`)
+
+	// Matches leading access specifiers such as "public:" inside a class body
+	accessSpecifierPattern = regexp.MustCompile(`^(?:(?:public|protected|private)\s*:\s*)+`)
+
+	// Matches the class keyword, whose members default to private access
+	classKeywordPattern = regexp.MustCompile(`\bclass\b`)
+
+	// Matches the colon that starts a constructor initializer list after the parameter list
+	initializerListPattern = regexp.MustCompile(`\)\s*(?:noexcept\s*)?:[^:]`)
+
+	// Matches an enum or enum class definition, which is not a class scope
+	enumPattern = regexp.MustCompile(`\benum\b`)
+
+	// Matches a namespace definition and captures its (possibly nested) name
+	namespacePattern = regexp.MustCompile(`^(?:inline\s+)?namespace\s*([\w:]*)`)
+
+	// Matches an #include directive and captures the included header as written
+	includePattern = regexp.MustCompile(`(?m)^[ \t]*#[ \t]*include[ \t]*([<"][^>"\n]*[>"])`)
+
+	// Matches a function signature ending just before its body or terminating semicolon
+	functionPattern = regexp.MustCompile(`([A-Za-z_][\w:]*~?[A-Za-z_]\w*|operator\s*[^\s(]+|~?[A-Za-z_]\w*)\s*\(([^()]*(?:\([^()]*\)[^()]*)*)\)\s*(?:const\s*)?(?:noexcept(?:\s*\([^)]*\))?\s*)?(?:override\s*|final\s*)*(?:->\s*[\w:<>,\s\*&]+)?(?:=\s*(?:0|default|delete)\s*)?$`)
+
+	// Words that look like calls in a signature position but are control flow
+	nonFunctionKeywords = map[string]bool{
+		"if": true, "for": true, "while": true, "switch": true, "return": true,
+		"sizeof": true, "catch": true, "decltype": true, "static_assert": true,
+	}
+)
+
+// stripCommentsAndStrings removes comments, string/char literals and preprocessor
+// lines so that braces and parentheses inside them don't confuse the scanner
+func stripCommentsAndStrings(code string) string {
+	var out strings.Builder
+	n := len(code)
+
+	for i := 0; i < n; i++ {
+		c := code[i]
+		switch {
+		case c == '/' && i+1 < n && code[i+1] == '/':
+			for i < n && code[i] != '\n' {
+				i++
+			}
+			out.WriteByte('\n')
+		case c == '/' && i+1 < n && code[i+1] == '*':
+			i += 2
+			for i+1 < n && !(code[i] == '*' && code[i+1] == '/') {
+				if code[i] == '\n' {
+					out.WriteByte('\n')
+				}
+				i++
+			}
+			i++
+		case c == '"' || c == '\'':
+			quote := c
+			i++
+			for i < n && code[i] != quote {
+				if code[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			out.WriteByte(quote)
+			out.WriteByte(quote)
+		case c == '#' && isLineStart(code, i):
+			// Skip the whole directive, including backslash continuations
+			for i < n && code[i] != '\n' {
+				if code[i] == '\\' && i+1 < n && code[i+1] == '\n' {
+					out.WriteByte('\n')
+					i++
+				}
+				i++
+			}
+			out.WriteByte('\n')
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	return out.String()
+}
+
+// isLineStart reports whether only whitespace precedes position i on its line
+func isLineStart(code string, i int) bool {
+	for j := i - 1; j >= 0 && code[j] != '\n'; j-- {
+		if code[j] != ' ' && code[j] != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// functionSpan locates a function definition within a source file
+type functionSpan struct {
+	Name      string // qualified with its class for member functions, e.g. Vector::normalize
+	StartLine int
+	EndLine   int
+}
+
+// parameter is a single function parameter
+type parameter struct {
+	Type string
+	Name string
+}
+
+// functionSignature is a function's qualified name and its parameters
+type functionSignature struct {
+	Name   string
+	Params []parameter
+}
+
+// String renders the signature with parameter types only, e.g. Calculator::add(int, int)
+func (sig functionSignature) String() string {
+	types := make([]string, len(sig.Params))
+	for i, param := range sig.Params {
+		types[i] = strings.Join(strings.Fields(param.Type), " ")
+	}
+	return sig.Name + "(" + strings.Join(types, ", ") + ")"
+}
+
+// constructorInfo describes one constructor of a class
+type constructorInfo struct {
+	Class  string
+	Params []parameter
+}
+
+// ClassInfo describes a class, struct or union defined at namespace scope
+type ClassInfo struct {
+	Name     string
+	Methods  []string // member functions declared or defined, unqualified
+	Template bool
+
+	// Access holds the access level (public, protected or private) of the methods declared
+	// in the class body; methods only defined out of line have none
+	Access map[string]string
+}
+
+// MethodsWithAccess returns the methods whose access level is one of levels, in declaration order
+func (class ClassInfo) MethodsWithAccess(levels []string) []string {
+	var methods []string
+	for _, method := range class.Methods {
+		for _, level := range levels {
+			if class.Access[method] == level {
+				methods = append(methods, method)
+				break
+			}
+		}
+	}
+	return methods
+}
+
+// SourceInfo is the structure of a single C++ source. It is computed once per file and run by
+// AnalyzeSource and shared by prompt building, change detection, declaration-only detection,
+// include context and traceability. Grouping goes by file name and coverage by the function
+// records of lcov, so neither uses it.
+type SourceInfo struct {
+	Namespaces    []string // qualified with their enclosing namespaces, e.g. geo::detail
+	Classes       []ClassInfo
+	FreeFunctions []string
+	Templates     []string // names of the class and function templates
+	Includes      []string // included headers as written, e.g. <vector> or "point.h"
+	Functions     []functionSpan
+	Constructors  []constructorInfo
+	Signatures    []functionSignature // every distinct signature of the functions above
+	Throwing      []string            // functions that throw or are declared noexcept(false)
+	ClockCalls    []string            // ways the code reads the current time, e.g. system_clock::now
+}
+
+// Overloads returns the signatures of every function name with more than one overload,
+// keyed by qualified name
+func (info SourceInfo) Overloads() map[string][]functionSignature {
+	byName := make(map[string][]functionSignature)
+	for _, sig := range info.Signatures {
+		byName[sig.Name] = append(byName[sig.Name], sig)
+	}
+	for name, sigs := range byName {
+		if len(sigs) < 2 {
+			delete(byName, name)
+		}
+	}
+	return byName
+}
+
+// ClassNames returns the names of the classes in definition order
+func (info SourceInfo) ClassNames() []string {
+	names := make([]string, len(info.Classes))
+	for i, class := range info.Classes {
+		names[i] = class.Name
+	}
+	return names
+}
+
+// DeclarationsOnly reports whether the code declares only types, such as enums, plain structs
+// and aliases, and no function, method or constructor that could be tested for behavior
+func (info SourceInfo) DeclarationsOnly() bool {
+	if len(info.Functions) > 0 || len(info.FreeFunctions) > 0 || len(info.Constructors) > 0 {
+		return false
+	}
+	for _, class := range info.Classes {
+		if len(class.Methods) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// parseFunctionName extracts the function name from a declaration or definition head,
+// returning false when the text is not a function signature. It also returns the number
+// of lines taken up by leading access specifiers, which precede the signature itself.
+func parseFunctionName(text string, inClass bool) (string, int, bool) {
+	specifiers := accessSpecifierPattern.FindString(text)
+	text = text[len(specifiers):]
+	skippedLines := strings.Count(specifiers, "\n")
+
+	// Drop constructor initializer lists so members aren't taken for the function
+	if loc := initializerListPattern.FindStringIndex(text); loc != nil {
+		text = text[:loc[0]+1]
+	}
+
+	loc := functionPattern.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return "", 0, false
+	}
+	name := strings.TrimSpace(text[loc[2]:loc[3]])
+	if nonFunctionKeywords[name] {
+		return "", 0, false
+	}
+
+	// Free functions need a return type; initializers and calls are not declarations
+	prefix := strings.TrimSpace(text[:loc[0]])
+	if prefix == "" && !inClass && !strings.Contains(name, "::") {
+		return "", 0, false
+	}
+	if strings.HasSuffix(prefix, "=") || strings.HasSuffix(prefix, ",") || strings.HasSuffix(prefix, "(") ||
+		(strings.HasSuffix(prefix, ":") && !strings.HasSuffix(prefix, "::")) {
+		return "", 0, false
+	}
+
+	return name, skippedLines, true
+}
+
+// parseConstructor returns the parameters of text when it declares or defines a constructor
+// of className, either inside the class body or out of line as className::className
+func parseConstructor(text string, className string) ([]parameter, bool) {
+	text = text[len(accessSpecifierPattern.FindString(text)):]
+	if loc := initializerListPattern.FindStringIndex(text); loc != nil {
+		text = text[:loc[0]+1]
+	}
+
+	match := functionPattern.FindStringSubmatch(text)
+	if match == nil {
+		return nil, false
+	}
+	name := strings.TrimSpace(match[1])
+	if name != className && name != className+"::"+className {
+		return nil, false
+	}
+
+	// Constructors have no return type, only specifiers like explicit
+	prefix := strings.Fields(strings.TrimSpace(text[:strings.Index(text, match[0])]))
+	for _, word := range prefix {
+		if word != "explicit" && word != "constexpr" && word != "inline" {
+			return nil, false
+		}
+	}
+
+	return parseParameters(match[2]), true
+}
+
+// parseSignatureParameters returns the parameters of the function declared or defined by text
+func parseSignatureParameters(text string) []parameter {
+	text = text[len(accessSpecifierPattern.FindString(text)):]
+	if loc := initializerListPattern.FindStringIndex(text); loc != nil {
+		text = text[:loc[0]+1]
+	}
+	if match := functionPattern.FindStringSubmatch(text); match != nil {
+		return parseParameters(match[2])
+	}
+	return nil
+}
+
+// parseParameters splits a parameter list into types and names, ignoring default values
+func parseParameters(list string) []parameter {
+	var params []parameter
+	for _, raw := range splitTopLevel(list, ',') {
+		if i := strings.Index(raw, "="); i >= 0 {
+			raw = raw[:i]
+		}
+		raw = strings.TrimSpace(raw)
+		if raw == "" || raw == "void" {
+			continue
+		}
+
+		// The last identifier is the name when something precedes it
+		param := parameter{Type: raw}
+		end := len(raw)
+		start := end
+		for start > 0 && (raw[start-1] == '_' || isAlphaNumeric(raw[start-1])) {
+			start--
+		}
+		if start > 0 && start < end && !isKnownTypeWord(raw[start:end]) {
+			param.Type = strings.TrimSpace(raw[:start])
+			param.Name = raw[start:end]
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// splitTopLevel splits s at sep, ignoring separators nested in (), <>, [] or {}
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '<', '[', '{':
+			depth++
+		case ')', '>', ']', '}':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// isAlphaNumeric reports whether c is an ASCII letter or digit
+func isAlphaNumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// stripTemplatePrefix removes a leading "template <...>" from a declaration, reporting whether there was one
+func stripTemplatePrefix(text string) (string, bool) {
+	if !strings.HasPrefix(text, "template") {
+		return text, false
+	}
+	start := strings.Index(text, "<")
+	if start < 0 {
+		return text, false
+	}
+
+	depth := 0
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '<':
+			depth++
+		case '>':
+			depth--
+			if depth == 0 {
+				return strings.TrimSpace(text[i+1:]), true
+			}
+		}
+	}
+	return text, false
+}
+
+// isKnownTypeWord reports whether word ends a type rather than naming a parameter, as in "unsigned int"
+func isKnownTypeWord(word string) bool {
+	switch word {
+	case "int", "long", "short", "char", "double", "float", "bool", "unsigned", "signed", "const":
+		return true
+	}
+	return false
+}
+
+// AnalyzeSource scans C++ source for its namespaces, the classes it defines with their
+// methods, the free functions declared or defined at namespace scope, templates, includes,
+// constructors and the location of every function body
+func AnalyzeSource(code string) SourceInfo {
+	var result SourceInfo
+	seenFunctions := make(map[string]bool)
+	seenNamespaces := make(map[string]bool)
+	seenTemplates := make(map[string]bool)
+	classIndex := make(map[string]int)
+
+	for _, match := range includePattern.FindAllStringSubmatch(code, -1) {
+		result.Includes = append(result.Includes, match[1])
+	}
+
+	const (
+		scopeNamespace = iota
+		scopeClass
+		scopeFunction
+		scopeOther
+	)
+
+	type scope struct {
+		kind      int
+		className string
+		namespace string // qualified name of a namespace scope
+		span      int    // index into result.Functions for function bodies
+		bodyStart int    // offset of a function body in clean
+		access    string // current access level in a class body
+	}
+
+	clean := stripCommentsAndStrings(code)
+	var scopes []scope
+	var stmt strings.Builder
+	line, stmtLine := 1, 0
+
+	// atNamespaceScope reports whether every enclosing scope is a namespace
+	atNamespaceScope := func() bool {
+		for _, s := range scopes {
+			if s.kind != scopeNamespace {
+				return false
+			}
+		}
+		return true
+	}
+
+	// enclosingClass returns the class directly enclosing the current position, if any
+	enclosingClass := func() (string, bool) {
+		if len(scopes) == 0 || scopes[len(scopes)-1].kind != scopeClass {
+			return "", false
+		}
+		return scopes[len(scopes)-1].className, true
+	}
+
+	seenConstructors := make(map[string]bool)
+	recordConstructor := func(text string, className string) {
+		params, ok := parseConstructor(text, className)
+		if !ok {
+			return
+		}
+		var types []string
+		for _, param := range params {
+			types = append(types, param.Type)
+		}
+		key := className + "(" + strings.Join(types, ",") + ")"
+		if !seenConstructors[key] {
+			seenConstructors[key] = true
+			result.Constructors = append(result.Constructors, constructorInfo{Class: className, Params: params})
+		}
+	}
+
+	// currentNamespace returns the qualified name of the innermost enclosing namespace
+	currentNamespace := func() string {
+		for i := len(scopes) - 1; i >= 0; i-- {
+			if scopes[i].kind == scopeNamespace {
+				return scopes[i].namespace
+			}
+		}
+		return ""
+	}
+
+	recordTemplate := func(text string, name string) {
+		_, isTemplate := stripTemplatePrefix(text[len(accessSpecifierPattern.FindString(text)):])
+		if isTemplate && !seenTemplates[name] {
+			seenTemplates[name] = true
+			result.Templates = append(result.Templates, name)
+		}
+	}
+
+	// Access specifiers lead the statement that follows them and stay in effect until the next one
+	updateAccess := func(text string) string {
+		if len(scopes) == 0 || scopes[len(scopes)-1].kind != scopeClass {
+			return ""
+		}
+		if specifiers := strings.Fields(strings.ReplaceAll(accessSpecifierPattern.FindString(text), ":", " ")); len(specifiers) > 0 {
+			scopes[len(scopes)-1].access = specifiers[len(specifiers)-1]
+		}
+		return scopes[len(scopes)-1].access
+	}
+
+	recordMethod := func(className string, name string, access string) {
+		i, ok := classIndex[className]
+		if !ok {
+			return
+		}
+		if access != "" {
+			if result.Classes[i].Access == nil {
+				result.Classes[i].Access = make(map[string]string)
+			}
+			if _, seen := result.Classes[i].Access[name]; !seen {
+				result.Classes[i].Access[name] = access
+			}
+		}
+		for _, method := range result.Classes[i].Methods {
+			if method == name {
+				return
+			}
+		}
+		result.Classes[i].Methods = append(result.Classes[i].Methods, name)
+	}
+
+	// Declarations and out-of-line definitions repeat a signature, only distinct ones count
+	seenSignatures := make(map[string]bool)
+	recordSignature := func(text string, name string) {
+		sig := functionSignature{Name: name, Params: parseSignatureParameters(text)}
+		if key := sig.String(); !seenSignatures[key] {
+			seenSignatures[key] = true
+			result.Signatures = append(result.Signatures, sig)
+		}
+	}
+
+	seenThrowing := make(map[string]bool)
+	recordThrowing := func(name string) {
+		if !seenThrowing[name] {
+			seenThrowing[name] = true
+			result.Throwing = append(result.Throwing, name)
+		}
+	}
+
+	recordFreeFunction := func(name string) {
+		// A qualified name like Class::method is an out-of-line member definition
+		if !strings.Contains(name, "::") && !seenFunctions[name] {
+			seenFunctions[name] = true
+			result.FreeFunctions = append(result.FreeFunctions, name)
+		}
+	}
+
+	for i := 0; i < len(clean); i++ {
+		c := clean[i]
+		switch c {
+		case '{':
+			text := strings.TrimSpace(stmt.String())
+			stmt.Reset()
+
+			className, inClass := enclosingClass()
+			access := updateAccess(text)
+			switch {
+			case namespacePattern.MatchString(text):
+				namespace := currentNamespace()
+				if name := namespacePattern.FindStringSubmatch(text)[1]; name != "" {
+					namespace = strings.TrimPrefix(namespace+"::"+name, "::")
+					if !seenNamespaces[namespace] {
+						seenNamespaces[namespace] = true
+						result.Namespaces = append(result.Namespaces, namespace)
+					}
+				}
+				scopes = append(scopes, scope{kind: scopeNamespace, namespace: namespace})
+			case strings.HasPrefix(text, "extern \"\""):
+				scopes = append(scopes, scope{kind: scopeNamespace, namespace: currentNamespace()})
+			case enumPattern.MatchString(text):
+				scopes = append(scopes, scope{kind: scopeOther})
+			case isClassDefinition(text):
+				body, isTemplate := stripTemplatePrefix(text)
+				name := classDefPattern.FindStringSubmatch(body)[1]
+				if atNamespaceScope() {
+					classIndex[name] = len(result.Classes)
+					result.Classes = append(result.Classes, ClassInfo{Name: name, Template: isTemplate})
+					recordTemplate(text, name)
+				}
+				// Class members are private by default, struct and union members public
+				defaultAccess := "public"
+				if classKeywordPattern.MatchString(body[:strings.Index(body, name)]) {
+					defaultAccess = "private"
+				}
+				scopes = append(scopes, scope{kind: scopeClass, className: name, access: defaultAccess})
+			case atNamespaceScope() || inClass:
+				name, skippedLines, ok := parseFunctionName(text, inClass)
+				if !ok {
+					scopes = append(scopes, scope{kind: scopeOther})
+					break
+				}
+				recordTemplate(text, name)
+				if inClass {
+					recordConstructor(text, className)
+					recordMethod(className, name, access)
+					name = className + "::" + name
+					recordSignature(text, name)
+				} else {
+					if i := strings.LastIndex(name, "::"); i >= 0 {
+						recordConstructor(text, name[:i])
+						recordMethod(name[strings.LastIndex(name[:i], ":")+1:i], name[i+2:], "")
+					}
+					recordFreeFunction(name)
+					recordSignature(text, name)
+				}
+				if noexceptFalsePattern.MatchString(text) {
+					recordThrowing(name)
+				}
+				result.Functions = append(result.Functions, functionSpan{Name: name, StartLine: stmtLine + skippedLines})
+				scopes = append(scopes, scope{kind: scopeFunction, span: len(result.Functions) - 1, bodyStart: i})
+			default:
+				scopes = append(scopes, scope{kind: scopeOther})
+			}
+		case '}':
+			stmt.Reset()
+			if len(scopes) > 0 {
+				closed := scopes[len(scopes)-1]
+				if closed.kind == scopeFunction {
+					result.Functions[closed.span].EndLine = line
+					if throwPattern.MatchString(clean[closed.bodyStart:i]) {
+						recordThrowing(result.Functions[closed.span].Name)
+					}
+				}
+				scopes = scopes[:len(scopes)-1]
+			}
+		case ';':
+			text := strings.TrimSpace(stmt.String())
+			if atNamespaceScope() {
+				if name, _, ok := parseFunctionName(text, false); ok {
+					recordTemplate(text, name)
+					recordFreeFunction(name)
+					recordSignature(text, name)
+					if noexceptFalsePattern.MatchString(text) {
+						recordThrowing(name)
+					}
+				}
+			} else if className, inClass := enclosingClass(); inClass {
+				access := updateAccess(text)
+				recordConstructor(text, className)
+				if name, _, ok := parseFunctionName(text, true); ok {
+					recordTemplate(text, name)
+					recordMethod(className, name, access)
+					recordSignature(text, className+"::"+name)
+					if noexceptFalsePattern.MatchString(text) {
+						recordThrowing(className + "::" + name)
+					}
+				}
+			}
+			stmt.Reset()
+		default:
+			if stmt.Len() == 0 && (c == ' ' || c == '\t' || c == '\n' || c == '\r') {
+				break
+			}
+			if stmt.Len() == 0 {
+				stmtLine = line
+			}
+			stmt.WriteByte(c)
+		}
+
+		if c == '\n' {
+			line++
+		}
+	}
+
+	seenClocks := make(map[string]bool)
+	for _, match := range clockPattern.FindAllStringSubmatch(clean, -1) {
+		call := strings.Join(strings.Fields(match[1]+match[2]), "")
+		if !seenClocks[call] {
+			seenClocks[call] = true
+			result.ClockCalls = append(result.ClockCalls, call)
+		}
+	}
+
+	return result
+}
+
+// clockPattern matches reads of the current time through the std::chrono clocks and the C time
+// functions, capturing the function
+var clockPattern = regexp.MustCompile(`\b((?:system|steady|high_resolution)_clock\s*::\s*now|std\s*::\s*time|std\s*::\s*clock|gettimeofday|clock_gettime|localtime|gmtime)\s*\(|\b(time)\s*\(\s*(?:nullptr|NULL|0)\s*\)`)
+
+// promptComments returns the text of the comments in code that start with marker, such as
+// "// @utg: focus on thread safety", in order of appearance
+func promptComments(code string, marker string) []string {
+	pattern := regexp.MustCompile(`(?m)(?://|/\*|^\s*\*)\s*` + regexp.QuoteMeta(marker) + `\s*(.*?)\s*(?:\*/)?\s*$`)
+	var hints []string
+	for _, match := range pattern.FindAllStringSubmatch(code, -1) {
+		if hint := strings.TrimSpace(match[1]); hint != "" {
+			hints = append(hints, hint)
+		}
+	}
+	return hints
+}
+
+// throwPattern matches a throw expression, but not a bare rethrow
+var throwPattern = regexp.MustCompile(`\bthrow\s*[^;\s]`)
+
+// noexceptFalsePattern matches an explicit potentially-throwing specification
+var noexceptFalsePattern = regexp.MustCompile(`\bnoexcept\s*\(\s*false\s*\)`)
+
+// isClassDefinition reports whether the text before a '{' starts a class, struct or union body
+func isClassDefinition(text string) bool {
+	body, _ := stripTemplatePrefix(text)
+	return classDefPattern.MatchString(body) && !strings.Contains(body, "(")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// standardFlag converts a configured standard such as "C++17" or "c++20" into a g++ -std flag
+func standardFlag(standard string) string {
+	standard = strings.ToLower(strings.TrimSpace(standard))
+	standard = strings.TrimPrefix(standard, "-std=")
+	if !strings.HasPrefix(standard, "c++") && !strings.HasPrefix(standard, "gnu++") {
+		standard = "c++" + standard
+	}
+	return "-std=" + standard
+}
+
+// standardResult records whether a test compiles under one C++ standard
+type standardResult struct {
+	Standard string
+	Err      error
+}
+
+// checkStandards compiles a test without linking under every standard listed in
+// Standards.CPPStandards, catching generated code that only builds under some of them
+func checkStandards(absTestFile string, sourceDir string, rules *Rules) []standardResult {
+	if len(rules.Standards.CPPStandards) == 0 {
+		return nil
+	}
+
+	includeArgs, err := testIncludeArgs(sourceDir, rules)
+	if err != nil {
+		return []standardResult{{Standard: "all", Err: err}}
+	}
+	if rules.Mocks.StubsDir != "" {
+		if absStubsDir, err := filepath.Abs(rules.Mocks.StubsDir); err == nil {
+			includeArgs = append(includeArgs, "-I"+absStubsDir)
+		}
+	}
+	// The same header lookup as the real compile: the mirrored source directory and RapidCheck
+	includeArgs = append(includeArgs, mirroredIncludeArgs(absTestFile, sourceDir, rules)...)
+	for _, arg := range rapidCheckCompileArgs(rules) {
+		if strings.HasPrefix(arg, "-I") {
+			includeArgs = append(includeArgs, arg)
+		}
+	}
+
+	var results []standardResult
+	for _, standard := range rules.Standards.CPPStandards {
+		flag := standardFlag(standard)
+		args := append([]string{flag, "-fsyntax-only"}, includeArgs...)
+		args = append(args, absTestFile)
+
+		cmd := exec.Command("g++", args...)
+		cmd.Dir = filepath.Dir(absTestFile)
+		result := standardResult{Standard: strings.TrimPrefix(flag, "-std=")}
+		if output, err := cmd.CombinedOutput(); err != nil {
+			log.Printf("Compilation under %s failed for %s:\n%s", result.Standard, absTestFile, string(output))
+			result.Err = fmt.Errorf("compilation under %s failed: %v", result.Standard, err)
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// formatStandardResults summarizes standard compatibility results on one line
+func formatStandardResults(results []standardResult) string {
+	var parts []string
+	for _, result := range results {
+		status := "✅"
+		if result.Err != nil {
+			status = "❌"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", result.Standard, status))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printStandardResults writes the standard compatibility of a test to the console
+func printStandardResults(testFile string, results []standardResult) {
+	if len(results) == 0 {
+		return
+	}
+	fmt.Printf("📐 C++ standards for %s: %s\n", testFile, formatStandardResults(results))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Values of OutputFormat.Tautologies, what happens to tests with assertions that always pass
+const (
+	tautologiesOff   = "off"
+	tautologiesWarn  = "warn"
+	tautologiesRetry = "retry"
+)
+
+// identityMacros pass whenever their first two arguments are the same expression
+var identityMacros = map[string]bool{
+	"EXPECT_EQ": true, "ASSERT_EQ": true, "EXPECT_LE": true, "ASSERT_LE": true,
+	"EXPECT_GE": true, "ASSERT_GE": true, "EXPECT_FLOAT_EQ": true, "ASSERT_FLOAT_EQ": true,
+	"EXPECT_DOUBLE_EQ": true, "ASSERT_DOUBLE_EQ": true, "EXPECT_NEAR": true, "ASSERT_NEAR": true,
+	"EXPECT_STREQ": true, "ASSERT_STREQ": true, "EXPECT_STRCASEEQ": true, "ASSERT_STRCASEEQ": true,
+}
+
+// constantConditions are the conditions that make a boolean assertion always pass
+var constantConditions = map[string][]string{
+	"EXPECT_TRUE": {"true", "1", "!false", "!0"}, "ASSERT_TRUE": {"true", "1", "!false", "!0"},
+	"CHECK": {"true", "1", "!false", "!0"}, "REQUIRE": {"true", "1", "!false", "!0"},
+	"EXPECT_FALSE": {"false", "0", "!true"}, "ASSERT_FALSE": {"false", "0", "!true"},
+	"CHECK_FALSE": {"false", "0", "!true"}, "REQUIRE_FALSE": {"false", "0", "!true"},
+}
+
+// tautologiesMode returns the configured handling of tautological assertions, off by default
+func (tg *TestGenerator) tautologiesMode() string {
+	switch mode := strings.ToLower(strings.TrimSpace(tg.rules.OutputFormat.Tautologies)); mode {
+	case tautologiesWarn, tautologiesRetry:
+		return mode
+	default:
+		return tautologiesOff
+	}
+}
+
+// findTautologies returns the assertions in code that pass regardless of the code under test,
+// such as EXPECT_EQ(x, x), EXPECT_TRUE(true) or CHECK(a == a). It is a pattern scan, so only
+// the obvious cases are caught.
+func findTautologies(code string) []string {
+	stripped := stripCommentsAndStrings(code)
+	var found []string
+	for _, match := range assertionCallPattern.FindAllStringSubmatchIndex(stripped, -1) {
+		macro := stripped[match[2]:match[3]]
+		if !identityMacros[macro] && constantConditions[macro] == nil {
+			continue
+		}
+		args, ok := macroArguments(stripped[match[1]:])
+		if !ok || len(args) == 0 {
+			continue
+		}
+		if isTautology(macro, args) {
+			found = append(found, fmt.Sprintf("%s(%s)", macro, strings.Join(args, ", ")))
+		}
+	}
+	return found
+}
+
+// isTautology reports whether an assertion with these arguments always passes
+func isTautology(macro string, args []string) bool {
+	if identityMacros[macro] {
+		return len(args) >= 2 && sameExpression(args[0], args[1])
+	}
+
+	condition := normalizeExpression(args[0])
+	for _, constant := range constantConditions[macro] {
+		if condition == constant {
+			return true
+		}
+	}
+	// A condition comparing an expression with itself, e.g. CHECK(a == a)
+	if !strings.HasSuffix(macro, "FALSE") && strings.Count(condition, "==") == 1 {
+		sides := strings.SplitN(condition, "==", 2)
+		return sameExpression(sides[0], sides[1])
+	}
+	return false
+}
+
+// sameExpression reports whether two expressions are written the same way. String literals
+// are blanked out by the scan, so expressions containing them are never considered the same.
+func sameExpression(a, b string) bool {
+	a, b = normalizeExpression(a), normalizeExpression(b)
+	if a == "" || strings.Contains(a, `""`) || strings.Contains(a, "''") {
+		return false
+	}
+	return a == b
+}
+
+// normalizeExpression removes whitespace and redundant outer parentheses from an expression
+func normalizeExpression(expr string) string {
+	expr = strings.Join(strings.Fields(expr), "")
+	for strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+		if args, ok := macroArguments(expr[1:]); !ok || len(args) != 1 || len(args[0]) != len(expr)-2 {
+			break
+		}
+		expr = expr[1 : len(expr)-1]
+	}
+	return expr
+}
+
+// checkTautologies rejects code with assertions that always pass
+func checkTautologies(code string) error {
+	if found := findTautologies(code); len(found) > 0 {
+		return fmt.Errorf("assertion %s always passes, whatever the code under test does", found[0])
+	}
+	return nil
+}
+
+// recordTautologies keeps the tautological assertions of a saved test for the summary
+func (tg *TestGenerator) recordTautologies(outputPath, code string) {
+	found := findTautologies(code)
+	if len(found) == 0 {
+		return
+	}
+	tg.tautologiesMu.Lock()
+	defer tg.tautologiesMu.Unlock()
+	if tg.tautologies == nil {
+		tg.tautologies = make(map[string][]string)
+	}
+	tg.tautologies[outputPath] = found
+}
+
+// printTautologies lists the saved tests with assertions that always pass
+func (tg *TestGenerator) printTautologies() {
+	tg.tautologiesMu.Lock()
+	defer tg.tautologiesMu.Unlock()
+	if len(tg.tautologies) == 0 {
+		return
+	}
+
+	paths := make([]string, 0, len(tg.tautologies))
+	for path := range tg.tautologies {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	fmt.Printf("⚠️  %d test files have assertions that always pass:\n", len(paths))
+	for _, path := range paths {
+		fmt.Printf("   - %s: %s\n", path, strings.Join(tg.tautologies[path], "; "))
+	}
+}
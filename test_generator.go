@@ -2,30 +2,366 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ollama/ollama/api"
 )
 
 type TestGenerator struct {
-	client *api.Client
-	rules  *Rules
+	client    *api.Client
+	rules     *Rules
+	framework frameworkProfile
+	options   GenerationOptions
+
+	// testCasePattern matches the definition of a test case with any of the framework's macros
+	testCasePattern *regexp.Regexp
+
+	// propertyBased is set when the tests include RapidCheck properties
+	propertyBased bool
+
+	// validators is the chain every model response has to pass
+	validators []ResponseValidator
+
+	// metrics collects the model usage of every generated file
+	metrics   []fileMetrics
+	metricsMu sync.Mutex
+
+	// fixtures is the shared fixtures header content, written once per run
+	fixtures     string
+	fixturesErr  error
+	fixturesOnce sync.Once
+
+	// sampleData holds the example inputs of TestCaseRules.SampleDataFile by method, read once per run
+	sampleData     map[string][]string
+	sampleDataErr  error
+	sampleDataOnce sync.Once
+
+	// existingTests lists the hand-written tests found in the test directories, once per run
+	existingTests     []string
+	existingTestsOnce sync.Once
+
+	// contextHeaders are headers given as context to every group in their directory
+	// instead of being grouped and tested themselves
+	contextHeaders map[string]string
+
+	// exampleSource and exampleTest are the demonstration pair shown in the prompt
+	exampleSource string
+	exampleTest   string
+	exampleOnce   sync.Once
+
+	// analyses caches the AnalyzeSource result of every code block of this run by file and hash
+	analyses   map[analysisKey]SourceInfo
+	analysesMu sync.Mutex
+
+	// compileDBMu serializes updates of the compilation database
+	compileDBMu sync.Mutex
+
+	// manifest holds the tests saved during this run for manifest.json
+	manifest   []manifestEntry
+	manifestMu sync.Mutex
+	// manifestTests holds the tests manifest.json listed at the start of the run, read once
+	manifestTests     map[string]bool
+	manifestTestsOnce sync.Once
+
+	// makefileMu serializes rewrites of the tests Makefile
+	makefileMu sync.Mutex
+
+	// written lists the tests saved during this run, in the order they were first written, and
+	// supportFiles the other files written for them
+	written      []string
+	supportFiles []string
+	writtenMu    sync.Mutex
+
+	// testCases counts the test cases in the test files saved during this run
+	testCases   int
+	testCasesMu sync.Mutex
+
+	// banners holds the rendered Output.Banner of each test file, by output path
+	banners   map[string]string
+	bannersMu sync.Mutex
+
+	// tautologies holds the always-passing assertions of the saved tests, by output path
+	tautologies   map[string][]string
+	tautologiesMu sync.Mutex
+}
+
+// GenerationOptions holds per-run settings that come from the command line rather than rules.yaml
+type GenerationOptions struct {
+	// SinceRef limits generation to functions changed since this git ref
+	SinceRef string
+
+	// DryRun generates tests without saving them
+	DryRun bool
+
+	// Debug adds the last raw model response of failed groups to the summary
+	Debug bool
+
+	// Strict fails every group that doesn't end up with a valid test file, including skipped ones
+	Strict bool
+
+	// Force overwrites existing files in the tests directory that weren't generated by utg
+	Force bool
+
+	// MaxTotalTests stops generating new test files once the saved ones hold this many test cases (0 = no limit)
+	MaxTotalTests int
 }
 
 func NewTestGenerator(client *api.Client, rules *Rules) *TestGenerator {
-	return &TestGenerator{client: client, rules: rules}
+	tg := &TestGenerator{
+		client:    client,
+		rules:     rules,
+		framework: getFrameworkProfile(rules.TestFramework),
+	}
+	tg.enablePropertyTests()
+	// Tests registered with the team's own macro are test cases too
+	if macro := strings.TrimSpace(rules.Output.RegistrationMacro.Name); macro != "" {
+		tg.framework = tg.framework.withTestMacros(macro)
+	}
+	tg.testCasePattern = tg.framework.testCasePattern()
+	tg.validators = tg.buildValidators()
+	return tg
 }
 
 // ProcessFiles processes all files and generates test cases for each
 func (tg *TestGenerator) ProcessFiles(files map[string]string) error {
 	log.Printf("Starting to process %d files", len(files))
+	start := time.Now()
+	tg.resetSourceInfo()
+
+	fileGroups := GroupFiles(tg.SplitContextHeaders(files))
+	log.Printf("Grouped files into %d base names", len(fileGroups))
+
+	baseNames := make([]string, 0, len(fileGroups))
+	for baseName := range fileGroups {
+		baseNames = append(baseNames, baseName)
+	}
+	sort.Strings(baseNames)
+
+	workers := tg.rules.ModelConfig.FileWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(baseNames) {
+		workers = len(baseNames)
+	}
+	log.Printf("Processing groups with %d workers", workers)
 
-	// Group files by their base name (without extension)
+	// Ctrl-C skips the files being generated, a second Ctrl-C right after aborts the run
+	runCtx, abort := context.WithCancel(context.Background())
+	defer abort()
+	interrupts := newInterruptHandler(abort)
+	defer interrupts.stop()
+
+	successCount := 0
+	failureCount := 0
+	var skipped, overBudget []string
+	var failures []groupFailure
+	var countMu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+
+	// Process each group
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for baseName := range jobs {
+				if runCtx.Err() != nil {
+					continue
+				}
+				if tg.testBudgetReached() {
+					countMu.Lock()
+					overBudget = append(overBudget, baseName)
+					countMu.Unlock()
+					continue
+				}
+				log.Printf("Processing group: %s", baseName)
+
+				fileCtx, done := interrupts.fileContext(runCtx)
+				processed, err := tg.ProcessGroup(fileCtx, fileGroups[baseName])
+				interrupted := fileCtx.Err() != nil
+				done()
+
+				countMu.Lock()
+				switch {
+				case interrupted:
+					log.Printf("Skipped group %s: interrupted", baseName)
+					skipped = append(skipped, baseName)
+				case err != nil:
+					log.Printf("Failed to process group %s: %v", baseName, err)
+					failureCount++
+					failures = append(failures, newGroupFailure(baseName, err))
+				case !processed:
+					// Only count groups that had something to generate
+					log.Printf("Skipping group %s: nothing to generate", baseName)
+				default:
+					successCount++
+					log.Printf("Successfully processed group: %s", baseName)
+				}
+				countMu.Unlock()
+			}
+		}()
+	}
+
+	for _, baseName := range baseNames {
+		jobs <- baseName
+	}
+	close(jobs)
+	wg.Wait()
+
+	log.Printf("Processing complete. Success: %d, Failures: %d, Skipped: %d", successCount, failureCount, len(skipped))
+	tg.printMetricsSummary()
+
+	generationLog := &generationRunLog{
+		Time:      start,
+		Seconds:   time.Since(start).Seconds(),
+		Groups:    len(fileGroups),
+		Generated: successCount,
+		Skipped:   skipped,
+		Aborted:   runCtx.Err() != nil,
+	}
+	for _, failure := range failures {
+		generationLog.Failed = append(generationLog.Failed, groupFailureRecord{Group: failure.BaseName, Error: failure.Err.Error()})
+	}
+	recordGenerationRun(generationLog, tg.rules)
+
+	if err := tg.writeManifest(); err != nil {
+		fmt.Printf("⚠️  Failed to write the test manifest: %v\n", err)
+	}
+	tg.writeMakefileIfEnabled()
+
+	tg.printFailures(failures)
+	tg.printTautologies()
+	if len(skipped) > 0 {
+		sort.Strings(skipped)
+		fmt.Printf("⏭️  Skipped %d files on interrupt:\n", len(skipped))
+		for _, baseName := range skipped {
+			fmt.Printf("   - %s\n", baseName)
+		}
+	}
+	if len(overBudget) > 0 {
+		fmt.Printf("🛑 Reached the limit of %d generated tests (%d written), skipped %d remaining groups\n",
+			tg.options.MaxTotalTests, tg.testCases, len(overBudget))
+	}
+	if runCtx.Err() != nil {
+		return fmt.Errorf("run aborted after %d of %d groups", successCount+failureCount+len(skipped), len(fileGroups))
+	}
+
+	if failureCount > 0 {
+		return fmt.Errorf("failed to process %d out of %d groups", failureCount, len(fileGroups))
+	}
+	if tg.options.Strict && len(skipped) > 0 {
+		return fmt.Errorf("%d of %d groups were skipped", len(skipped), len(fileGroups))
+	}
+
+	return nil
+}
+
+// maxResponseSnippetBytes bounds the raw response shown for a failed group
+const maxResponseSnippetBytes = 500
+
+// groupFailure records why a group failed to generate
+type groupFailure struct {
+	BaseName     string
+	Err          error
+	LastResponse string
+}
+
+// newGroupFailure wraps a group's error, keeping the model's last raw response when there is one
+func newGroupFailure(baseName string, err error) groupFailure {
+	failure := groupFailure{BaseName: baseName, Err: err}
+	var genErr *generationError
+	if errors.As(err, &genErr) {
+		failure.LastResponse = genErr.LastResponse
+	}
+	return failure
+}
+
+// printFailures lists each failed group with its last error, and its last raw response in debug mode
+func (tg *TestGenerator) printFailures(failures []groupFailure) {
+	if len(failures) == 0 {
+		return
+	}
+
+	sort.Slice(failures, func(i, j int) bool { return failures[i].BaseName < failures[j].BaseName })
+	fmt.Printf("❌ %d groups failed:\n", len(failures))
+	for _, failure := range failures {
+		fmt.Printf("   - %s: %v\n", failure.BaseName, failure.Err)
+		if tg.options.Debug && failure.LastResponse != "" {
+			snippet := failure.LastResponse
+			if len(snippet) > maxResponseSnippetBytes {
+				snippet = snippet[:maxResponseSnippetBytes] + "..."
+			}
+			fmt.Printf("     Last response:\n%s\n", snippet)
+		}
+	}
+}
+
+// SplitContextHeaders removes the headers matching Paths.ContextHeaders from files and keeps
+// them as context for the groups in the same directory. It returns the remaining files;
+// splitting them again changes nothing.
+func (tg *TestGenerator) SplitContextHeaders(files map[string]string) map[string]string {
+	if len(tg.rules.Paths.ContextHeaders) == 0 {
+		return files
+	}
+
+	if tg.contextHeaders == nil {
+		tg.contextHeaders = make(map[string]string)
+	}
+	remaining := make(map[string]string)
+	for filename, content := range files {
+		if isHeaderFile(filename) && tg.isContextHeader(filename) {
+			log.Printf("Using %s as context only", filename)
+			tg.contextHeaders[filename] = content
+			continue
+		}
+		remaining[filename] = content
+	}
+	return remaining
+}
+
+// isContextHeader reports whether a file matches one of the Paths.ContextHeaders globs
+func (tg *TestGenerator) isContextHeader(filename string) bool {
+	relPath, err := filepath.Rel(tg.rules.Paths.CodebaseDir, filename)
+	if err != nil {
+		relPath = filename
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	for _, pattern := range tg.rules.Paths.ContextHeaders {
+		if matchGlob(pattern, relPath) || matchGlob(pattern, filepath.Base(filename)) {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleContextHeaders returns the context headers in the same directory as filename, sorted by name
+func (tg *TestGenerator) moduleContextHeaders(filename string) []groupFile {
+	var headers []groupFile
+	for header, content := range tg.contextHeaders {
+		if filepath.Dir(header) == filepath.Dir(filename) {
+			headers = append(headers, groupFile{Name: header, Content: content})
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Name < headers[j].Name })
+	return headers
+}
+
+// GroupFiles groups files by their base name (without extension) so that a
+// header and its implementation are processed together
+func GroupFiles(files map[string]string) map[string]map[string]string {
 	fileGroups := make(map[string]map[string]string)
 
 	for filename, content := range files {
@@ -41,89 +377,208 @@ func (tg *TestGenerator) ProcessFiles(files map[string]string) error {
 		fileGroups[baseName][filename] = content
 	}
 
-	log.Printf("Grouped files into %d base names", len(fileGroups))
+	return fileGroups
+}
 
-	successCount := 0
-	failureCount := 0
+// TestTargetFile returns the file a group's test is generated for: its first implementation
+// file, or its first header when testing prebuilt libraries and there is no implementation.
+// It returns "" when the group has nothing to test.
+func TestTargetFile(group map[string]string, rules *Rules) string {
+	var implFile, headerFile string
+	for filename := range group {
+		if isImplementationFile(filename, rules) {
+			if implFile == "" || filename < implFile {
+				implFile = filename
+			}
+		} else if isHeaderFile(filename) {
+			if headerFile == "" || filename < headerFile {
+				headerFile = filename
+			}
+		}
+	}
+	if implFile == "" && rules.UsesPrebuiltLibraries() {
+		return headerFile
+	}
+	return implFile
+}
 
-	// Process each group
-	for baseName, group := range fileGroups {
-		log.Printf("Processing group: %s", baseName)
+// ProcessGroup generates the test file for a single group of files.
+// It reports false when the group has no implementation file or no changes to test.
+func (tg *TestGenerator) ProcessGroup(ctx context.Context, group map[string]string) (bool, error) {
+	// Find every implementation file and header of the group
+	var implFiles, headerFiles []string
+
+	for filename := range group {
+		if isImplementationFile(filename, tg.rules) {
+			implFiles = append(implFiles, filename)
+		} else if isHeaderFile(filename) {
+			headerFiles = append(headerFiles, filename)
+		}
+	}
+	sort.Strings(implFiles)
+	sort.Strings(headerFiles)
+
+	// The test is named after the first implementation file. Prebuilt libraries only
+	// ship headers, so those become the test target.
+	var implFile string
+	if len(implFiles) > 0 {
+		implFile = implFiles[0]
+	} else if tg.rules.UsesPrebuiltLibraries() && len(headerFiles) > 0 {
+		implFile = headerFiles[0]
+	}
 
-		// Find .cpp/.cc file (implementation)
-		var implFile, implContent string
-		var headerContent string
+	// Only process if we have something to test
+	if implFile == "" {
+		return false, nil
+	}
 
-		for filename, content := range group {
-			if strings.HasSuffix(filename, ".cpp") || strings.HasSuffix(filename, ".cc") {
-				implFile = filename
-				implContent = content
-			} else if strings.HasSuffix(filename, ".h") || strings.HasSuffix(filename, ".hpp") {
-				// headerFile = filename
-				headerContent = content
-			}
+	// In incremental mode only functions touched since the ref are tested
+	var focusFunctions []string
+	if tg.options.SinceRef != "" {
+		changed, err := tg.changedFunctions(group)
+		if err != nil {
+			return true, err
+		}
+		if len(changed) == 0 {
+			log.Printf("No functions changed since %s in %s", tg.options.SinceRef, implFile)
+			return false, nil
 		}
+		focusFunctions = changed
+	}
 
-		// Only process if we have an implementation file
-		if implFile == "" {
-			log.Printf("Skipping group %s: no implementation file found", baseName)
-			continue
+	// Enums, plain structs and aliases have no behavior to test
+	var extraPrompt string
+	if mode := tg.declarationOnlyMode(); mode != declarationOnlyGenerate && tg.groupDeclaresOnlyTypes(group) {
+		declarationOnlyNotice(implFile, mode)
+		if mode == declarationOnlySkip {
+			return false, nil
 		}
+		extraPrompt = tg.staticAssertPrompt(group)
+	}
 
-		// Combine header and implementation content
-		combinedContent := tg.combineHeaderAndImplementation(headerContent, implContent)
+	if _, err := tg.writeSharedFixtures(); err != nil {
+		return true, err
+	}
+	if _, err := tg.loadSampleData(); err != nil {
+		return true, err
+	}
 
-		// Use the implementation file name for generating test filename
-		if err := tg.processFile(implFile, combinedContent); err != nil {
-			log.Printf("Failed to process group %s: %v", baseName, err)
-			failureCount++
-			continue
+	// Combine header and implementation content
+	headers := make([]groupFile, 0, len(headerFiles))
+	for _, filename := range headerFiles {
+		headers = append(headers, groupFile{Name: filename, Content: group[filename]})
+	}
+	headers = append(headers, tg.moduleContextHeaders(implFile)...)
+	impls := make([]groupFile, 0, len(implFiles))
+	for _, filename := range implFiles {
+		impls = append(impls, groupFile{Name: filename, Content: group[filename]})
+	}
+	headers = append(headers, tg.includedContextHeaders(implFile, append(append([]groupFile{}, headers...), impls...))...)
+	combinedContent := tg.combineHeaderAndImplementation(headers, impls)
+
+	// Use the implementation file name for generating test filename
+	groupFiles := append(append([]string{}, headerFiles...), implFiles...)
+	if err := tg.processFile(ctx, implFile, groupFiles, combinedContent, extraPrompt, focusFunctions); err != nil {
+		if errors.Is(err, errNoTestsWritten) {
+			return false, nil
 		}
+		return true, err
+	}
+
+	return true, nil
+}
+
+// changedFunctions returns the functions in a group whose bodies overlap lines changed since SinceRef
+func (tg *TestGenerator) changedFunctions(group map[string]string) ([]string, error) {
+	var changed []string
+	seen := make(map[string]bool)
 
-		successCount++
-		log.Printf("Successfully processed group: %s", baseName)
+	filenames := make([]string, 0, len(group))
+	for filename := range group {
+		filenames = append(filenames, filename)
 	}
+	sort.Strings(filenames)
 
-	log.Printf("Processing complete. Success: %d, Failures: %d", successCount, failureCount)
+	for _, filename := range filenames {
+		ranges, err := ChangedLineRanges(tg.options.SinceRef, filename)
+		if err != nil {
+			return nil, err
+		}
 
-	if failureCount > 0 {
-		return fmt.Errorf("failed to process %d out of %d groups", failureCount, len(fileGroups))
+		for _, function := range tg.sourceInfo(filename, group[filename]).Functions {
+			for _, r := range ranges {
+				if r.overlaps(function.StartLine, function.EndLine) && !seen[function.Name] {
+					seen[function.Name] = true
+					changed = append(changed, function.Name)
+				}
+			}
+		}
 	}
 
-	return nil
+	return changed, nil
 }
 
-// combineHeaderAndImplementation combines header and implementation content
-func (tg *TestGenerator) combineHeaderAndImplementation(headerContent, implContent string) string {
-	var combined strings.Builder
+// groupFile is a file of the codebase together with its content
+type groupFile struct {
+	Name    string
+	Content string
+}
+
+// combineHeaderAndImplementation combines all headers and implementation files of a group,
+// labelling each with its filename so the model can tell them apart
+func (tg *TestGenerator) combineHeaderAndImplementation(headers []groupFile, impls []groupFile) string {
+	var parts []string
 
 	// Add header content first (if exists)
-	if headerContent != "" {
-		combined.WriteString("// Header file content:\n")
-		combined.WriteString(headerContent)
-		combined.WriteString("\n\n")
+	for _, header := range headers {
+		if header.Content != "" {
+			parts = append(parts, fmt.Sprintf("// Header file: %s\n%s", filepath.Base(header.Name), header.Content))
+		}
 	}
 
 	// Add implementation content
-	if implContent != "" {
-		combined.WriteString("// Implementation file content:\n")
-		combined.WriteString(implContent)
+	for _, impl := range impls {
+		if impl.Content != "" {
+			parts = append(parts, fmt.Sprintf("// Implementation file: %s\n%s", filepath.Base(impl.Name), impl.Content))
+		}
 	}
 
-	return combined.String()
+	return strings.Join(parts, "\n\n")
 }
 
 // processFile processes a single file and generates its test case
-func (tg *TestGenerator) processFile(filename, content string) error {
+func (tg *TestGenerator) processFile(ctx context.Context, filename string, groupFiles []string, content, extraPrompt string, focusFunctions []string) error {
+	outputPath := tg.testOutputPath(filename)
+	if !tg.options.DryRun {
+		// Don't spend a model call on a test that can't be saved
+		if err := tg.checkOverwrite(outputPath); err != nil {
+			return err
+		}
+	}
+
 	// Generate unit tests for the file
-	testCode, err := tg.GenerateUnitTests(content, "")
+	testCode, metrics, err := tg.GenerateUnitTests(ctx, filename, content, extraPrompt, focusFunctions)
+	tg.recordMetrics(filename, metrics)
 	if err != nil {
-		return fmt.Errorf("failed to generate unit tests: %v", err)
+		err = fmt.Errorf("failed to generate unit tests: %w", err)
+		if tg.options.DryRun || ctx.Err() != nil || !isEmptyTestsCause(err) {
+			return err
+		}
+		return tg.handleEmptyTests(filename, outputPath, err)
 	}
 
-	// Generate output filename
-	outputFilename := tg.generateTestFilename(filename)
-	outputPath := filepath.Join(tg.rules.Paths.TestsDir, outputFilename)
+	testCode = tg.finishTestCode(testCode, filename, content)
+
+	if tg.options.DryRun {
+		log.Printf("Dry run, not saving the test for %s (%d bytes)", filename, len(testCode))
+		return nil
+	}
+	if tg.countTestCases(testCode) == 0 {
+		return tg.handleEmptyTests(filename, outputPath, fmt.Errorf("the generated test defines no test cases"))
+	}
+	if tg.rules.Output.Banner != "" {
+		tg.setBanner(outputPath, tg.renderBanner(filename, metrics.Model, time.Now()))
+	}
 
 	// Save the generated test code
 	if err := tg.saveTestFile(outputPath, testCode); err != nil {
@@ -131,11 +586,87 @@ func (tg *TestGenerator) processFile(filename, content string) error {
 	}
 
 	log.Printf("Generated test file: %s (%d bytes)", outputPath, len(testCode))
+	tg.recordManifestEntry(filename, groupFiles, outputPath, metrics.Model)
+	if tg.tautologiesMode() == tautologiesWarn {
+		tg.recordTautologies(outputPath, testCode)
+	}
+
+	// Editors like clangd resolve the test's includes through the compilation database
+	if tg.rules.Output.EmitCompileDB {
+		if err := tg.updateCompileDB(outputPath); err != nil {
+			fmt.Printf("⚠️  Failed to update compilation database: %v\n", err)
+		}
+	}
+	if tg.rules.ModelConfig.RepairRounds > 0 {
+		testCode = tg.repairCompileErrors(ctx, filename, content, outputPath, testCode)
+	}
+
+	if tg.rules.Coverage.ImprovementRounds > 0 {
+		tg.improveCoverage(ctx, filename, content, outputPath, testCode, focusFunctions)
+	}
+
+	if tg.options.MaxTotalTests > 0 {
+		if data, err := os.ReadFile(outputPath); err == nil {
+			tg.recordTestCases(tg.countTestCases(string(data)))
+		}
+	}
+
+	if tg.options.Strict {
+		return tg.verifyTestFile(outputPath)
+	}
 	return nil
 }
 
-// GenerateUnitTests generates unit tests for the given code
-func (tg *TestGenerator) GenerateUnitTests(code string, extraPrompt string) (string, error) {
+// countTestCases returns the number of test cases the code defines with the framework's test macros
+func (tg *TestGenerator) countTestCases(code string) int {
+	return len(tg.testCasePattern.FindAllString(stripCommentsAndStrings(code), -1))
+}
+
+// recordTestCases adds the test cases of a saved test file to the run's total
+func (tg *TestGenerator) recordTestCases(count int) {
+	tg.testCasesMu.Lock()
+	defer tg.testCasesMu.Unlock()
+	tg.testCases += count
+}
+
+// testBudgetReached reports whether the run has generated MaxTotalTests test cases
+func (tg *TestGenerator) testBudgetReached() bool {
+	if tg.options.MaxTotalTests <= 0 {
+		return false
+	}
+	tg.testCasesMu.Lock()
+	defer tg.testCasesMu.Unlock()
+	return tg.testCases >= tg.options.MaxTotalTests
+}
+
+// verifyTestFile checks that a saved test file exists and holds valid test code
+func (tg *TestGenerator) verifyTestFile(outputPath string) error {
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return fmt.Errorf("test file was not written: %v", err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return fmt.Errorf("test file %s is empty", outputPath)
+	}
+	if !tg.isValidCppCode(string(data)) {
+		return fmt.Errorf("test file %s does not contain valid C++ code", outputPath)
+	}
+	return nil
+}
+
+// finishTestCode applies the post-processing every generated test goes through before saving
+func (tg *TestGenerator) finishTestCode(testCode, filename, content string) string {
+	// Every test should name the function it covers
+	if tg.rules.OutputFormat.TraceabilityComments {
+		testCode = ensureTraceabilityComments(testCode, traceabilityTargets(tg.sourceInfo(filename, content)), tg.framework.TestMacros)
+	}
+	return testCode
+}
+
+// GenerateUnitTests generates unit tests for the given code. When focusFunctions is
+// non-empty the tests are limited to those functions. filename is the file the tests target and
+// selects its language profile. The model usage of all attempts is returned alongside the tests.
+func (tg *TestGenerator) GenerateUnitTests(ctx context.Context, filename string, code string, extraPrompt string, focusFunctions []string) (string, modelMetrics, error) {
 	log.Printf("Generating unit tests with model %s (code length: %d bytes)",
 		tg.rules.ModelConfig.PrimaryModel, len(code))
 
@@ -145,10 +676,10 @@ func (tg *TestGenerator) GenerateUnitTests(code string, extraPrompt string) (str
 	fmt.Println("Original imports extracted:", originalImports)
 
 	// Get available models
-	resp, err := tg.client.List(context.Background())
+	resp, err := tg.client.List(ctx)
 	if err != nil {
 		log.Printf("Failed to list models: %v", err)
-		return "", err
+		return "", modelMetrics{}, err
 	}
 
 	// Build list of models to try
@@ -156,27 +687,110 @@ func (tg *TestGenerator) GenerateUnitTests(code string, extraPrompt string) (str
 	log.Printf("Available models from server: %v", tg.getModelNames(resp.Models))
 	log.Printf("Models to try in order: %v", modelsToTry)
 
+	// Utility files without classes need free-function oriented guidance
+	info := tg.sourceInfo(filename, code)
+	freeFunctions := info.FreeFunctions
+	if tg.rules.Naming.DisambiguateOverloads {
+		freeFunctions = expandOverloads(freeFunctions, info)
+	}
+	freeFunctionsOnly := len(info.Classes) == 0 && len(freeFunctions) > 0
+	log.Printf("Detected %d classes and %d free functions", len(info.Classes), len(freeFunctions))
+
 	// Get methods to test
-	methods := tg.getMethodsToTest()
+	methods := tg.getMethodsToTest(info, freeFunctionsOnly, freeFunctions)
+	if len(focusFunctions) > 0 {
+		methods = focusFunctions
+		extraPrompt = strings.TrimSpace(extraPrompt + "\nOnly write tests for the functions listed under 'Focus on testing'. " +
+			"They changed recently; the rest of the code is already tested.")
+	}
 	methodsList := strings.Join(methods, ", ")
 
+	// Developers can steer generation from comments in the code itself
+	if hints := promptComments(code, tg.promptCommentMarker()); len(hints) > 0 {
+		log.Printf("Adding %d prompt comments from %s", len(hints), filename)
+		extraPrompt = strings.TrimSpace(extraPrompt + "\n" + strings.Join(hints, "\n"))
+	}
+
+	// Hand-written tests show the house style and what is covered already
+	if existing := tg.existingTestsPrompt(filename); existing != "" {
+		extraPrompt = strings.TrimSpace(extraPrompt + "\n" + existing)
+	}
+
 	// Generate prompt with original imports
-	prompt := tg.generatePrompt(code, methodsList, extraPrompt, originalImports)
-	log.Printf("Sending API request with prompt (%d bytes)", len(prompt))
+	profile, ok := languageProfileFor(filename, tg.rules)
+	if !ok {
+		profile = cppProfile
+	}
+	prompt := tg.generatePrompt(code, info, methodsList, extraPrompt, originalImports, freeFunctionsOnly, profile)
 
-	// Create base request
+	// Create base request. Chat models get the standing instructions as a system message.
 	req := api.GenerateRequest{
-		Model:  tg.rules.ModelConfig.PrimaryModel,
-		Prompt: prompt,
-		Options: map[string]interface{}{
-			"num_ctx":     4096,
-			"num_predict": 1024,
-			"temperature": 0.7,
-		},
+		Model:     tg.rules.ModelConfig.PrimaryModel,
+		Prompt:    prompt.combined(),
+		Options:   tg.buildModelOptions(),
+		KeepAlive: tg.keepAlive(),
 	}
+	if tg.useChatAPI() {
+		req.System = prompt.System
+		req.Prompt = prompt.User
+	}
+	log.Printf("Sending API request with prompt (%d bytes)", len(req.System)+len(req.Prompt))
 
 	// Try each model with retries
-	return tg.tryModelsWithRetries(req, modelsToTry, methods)
+	return tg.tryModelsWithRetries(ctx, req, modelsToTry, methods)
+}
+
+// buildModelOptions builds the generation options sent to Ollama, applying configured overrides.
+// A fixed seed only makes output reproducible when the model and backend support it.
+func (tg *TestGenerator) buildModelOptions() map[string]interface{} {
+	options := map[string]interface{}{
+		"num_ctx":     4096,
+		"num_predict": 1024,
+		"temperature": 0.7,
+	}
+
+	if tg.rules.ModelConfig.Options.Temperature != nil {
+		options["temperature"] = *tg.rules.ModelConfig.Options.Temperature
+	}
+	if tg.rules.ModelConfig.Options.Seed != nil {
+		options["seed"] = *tg.rules.ModelConfig.Options.Seed
+	}
+
+	return options
+}
+
+// parseKeepAlive parses how long Ollama keeps the model loaded after a request: a duration like
+// "10m", a number of seconds, or a negative value to keep it loaded indefinitely. An empty value
+// leaves Ollama's default.
+func parseKeepAlive(value string) (*api.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return &api.Duration{Duration: -1}, nil
+		}
+		return &api.Duration{Duration: time.Duration(seconds) * time.Second}, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a duration nor a number of seconds", value)
+	}
+	if duration < 0 {
+		duration = -1
+	}
+	return &api.Duration{Duration: duration}, nil
+}
+
+// keepAlive returns the configured ModelConfig.KeepAlive for model requests
+func (tg *TestGenerator) keepAlive() *api.Duration {
+	keepAlive, err := parseKeepAlive(tg.rules.ModelConfig.KeepAlive)
+	if err != nil {
+		log.Printf("Ignoring keep_alive: %v", err)
+		return nil
+	}
+	return keepAlive
 }
 
 // buildModelList builds the list of models to try in order
@@ -215,11 +829,21 @@ func (tg *TestGenerator) getModelNames(models []api.ListModelResponse) []string
 }
 
 // getMethodsToTest determines which methods to test based on configuration
-func (tg *TestGenerator) getMethodsToTest() []string {
+func (tg *TestGenerator) getMethodsToTest(info SourceInfo, freeFunctionsOnly bool, freeFunctions []string) []string {
 	if tg.rules.MethodsToTest.Source == "manual" {
 		return tg.rules.MethodsToTest.ManualList
 	}
 
+	// Files without classes are tested through their free functions
+	if freeFunctionsOnly {
+		return freeFunctions
+	}
+
+	// Class bodies tell which methods are within the configured access levels
+	if methods := tg.accessibleMethods(info); len(methods) > 0 {
+		return append(methods, freeFunctions...)
+	}
+
 	// Default methods to test for C++
 	return []string{
 		"all public methods",
@@ -230,25 +854,409 @@ func (tg *TestGenerator) getMethodsToTest() []string {
 	}
 }
 
+// accessLevels returns the configured MethodsToTest.AccessLevels, public by default. Private
+// methods can't be called from a test, so only public and protected are accepted.
+func (tg *TestGenerator) accessLevels() []string {
+	var levels []string
+	for _, level := range tg.rules.MethodsToTest.AccessLevels {
+		level = strings.ToLower(strings.TrimSpace(level))
+		if level == "public" || level == "protected" {
+			levels = append(levels, level)
+		} else {
+			log.Printf("Ignoring access level %q, only public and protected methods can be tested", level)
+		}
+	}
+	if len(levels) == 0 {
+		return []string{"public"}
+	}
+	return levels
+}
+
+// accessibleMethods returns the methods of the analyzed classes within the configured access
+// levels, qualified with their class
+func (tg *TestGenerator) accessibleMethods(info SourceInfo) []string {
+	var methods []string
+	for _, class := range info.Classes {
+		for _, method := range class.MethodsWithAccess(tg.accessLevels()) {
+			methods = append(methods, class.Name+"::"+method)
+		}
+	}
+	return methods
+}
+
+// protectedAccessGuidance returns the prompt line on reaching the protected methods that are
+// to be tested, which tests can only call through a subclass
+func (tg *TestGenerator) protectedAccessGuidance(info SourceInfo) string {
+	if tg.rules.MethodsToTest.Source == "manual" {
+		return ""
+	}
+	testsProtected := false
+	for _, level := range tg.accessLevels() {
+		testsProtected = testsProtected || level == "protected"
+	}
+	if !testsProtected {
+		return ""
+	}
+
+	var protected []string
+	for _, class := range info.Classes {
+		for _, method := range class.MethodsWithAccess([]string{"protected"}) {
+			protected = append(protected, class.Name+"::"+method)
+		}
+	}
+	if len(protected) == 0 {
+		return ""
+	}
+	return "- These methods are protected: " + strings.Join(protected, ", ") + ". Test them through a test-only " +
+		"subclass that exposes them with using-declarations, and don't change the class under test\n"
+}
+
+// perMethodTestCounts matches the configured per-method overrides against the functions found in
+// the code and returns "method: count" entries for methods whose count differs from the default
+func (tg *TestGenerator) perMethodTestCounts(info SourceInfo) []string {
+	overrides := tg.rules.TestCaseRules.PerMethodOverrides
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	// Longer patterns are more specific, so they win when several match
+	patterns := make([]string, 0, len(overrides))
+	for pattern := range overrides {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	names := append([]string{}, info.FreeFunctions...)
+	for _, function := range info.Functions {
+		names = append(names, function.Name)
+	}
+
+	var counts []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		shortName := name[strings.LastIndex(name, ":")+1:]
+		for _, pattern := range patterns {
+			if matchGlob(pattern, name) || matchGlob(pattern, shortName) {
+				if overrides[pattern] != tg.rules.TestCaseRules.PerMethod {
+					counts = append(counts, fmt.Sprintf("%s: %d", name, overrides[pattern]))
+				}
+				break
+			}
+		}
+	}
+
+	return counts
+}
+
+// classSuiteGuidance returns the prompt line asking for one test suite per class when the
+// code defines several classes
+func (tg *TestGenerator) classSuiteGuidance(info SourceInfo) string {
+	classes := info.ClassNames()
+	if len(classes) < 2 {
+		return ""
+	}
+
+	suites := make([]string, len(classes))
+	for i, class := range classes {
+		suites[i] = class + " -> " + class + "Test"
+	}
+	return fmt.Sprintf("- The code defines %d classes. Write a separate test suite for every one of them, "+
+		"named after its class (%s), and don't leave any class untested\n", len(classes), strings.Join(suites, ", "))
+}
+
+// overloadGuidance returns the prompt line listing every overload of overloaded functions,
+// so that each gets its own tests named after its argument types
+func overloadGuidance(info SourceInfo) string {
+	overloads := info.Overloads()
+	if len(overloads) == 0 {
+		return ""
+	}
+
+	var signatures []string
+	for _, sig := range info.Signatures {
+		if _, ok := overloads[sig.Name]; ok {
+			signatures = append(signatures, sig.String())
+		}
+	}
+	return "- These functions are overloaded. Test every overload separately and put its argument types in the test name " +
+		"(e.g. AddInt and AddDouble for add(int) and add(double)): " + strings.Join(signatures, ", ") + "\n"
+}
+
+// defaultPromptCommentMarker introduces a code comment that is added to the file's prompt
+const defaultPromptCommentMarker = "@utg:"
+
+// promptCommentMarker returns the configured marker of prompt comments
+func (tg *TestGenerator) promptCommentMarker() string {
+	if marker := tg.rules.LLMPromptGuidance.CommentMarker; marker != "" {
+		return marker
+	}
+	return defaultPromptCommentMarker
+}
+
+// exceptionGuidance returns the prompt line asking for exception tests of the functions
+// that can throw, using the framework's throw assertions
+func (tg *TestGenerator) exceptionGuidance(info SourceInfo) string {
+	if len(info.Throwing) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("- These functions can throw: %s. Test the inputs that make them throw with %s and "+
+		"check that valid inputs don't throw with %s\n",
+		strings.Join(info.Throwing, ", "), tg.framework.Assertions["throws"], tg.framework.Assertions["no_throw"])
+}
+
+// clockGuidance returns the prompt line for code that reads the current time: with
+// Mocks.MockTime the model controls the time, otherwise it avoids depending on it
+func (tg *TestGenerator) clockGuidance(info SourceInfo) string {
+	if len(info.ClockCalls) == 0 {
+		return ""
+	}
+	clocks := strings.Join(info.ClockCalls, ", ")
+	if tg.rules.Mocks.MockTime {
+		return "- The code reads the current time (" + clocks + "). Make the tests deterministic: inject a clock " +
+			"abstraction or a fixed time point where the code allows it, and assert against that fixed time\n"
+	}
+	return "- The code reads the current time (" + clocks + "). Don't assert exact times or dates; " +
+		"check relative results or ranges that hold no matter when the tests run\n"
+}
+
+// expandOverloads replaces every overloaded name in names with the signatures of its overloads
+func expandOverloads(names []string, info SourceInfo) []string {
+	overloads := info.Overloads()
+	if len(overloads) == 0 {
+		return names
+	}
+
+	var expanded []string
+	for _, name := range names {
+		sigs, ok := overloads[name]
+		if !ok {
+			expanded = append(expanded, name)
+			continue
+		}
+		for _, sig := range sigs {
+			expanded = append(expanded, sig.String())
+		}
+	}
+	return expanded
+}
+
+// constructionGuidance returns prompt lines explaining how to construct classes without a
+// default constructor, using the configured construction snippets or suggested arguments
+func (tg *TestGenerator) constructionGuidance(info SourceInfo) []string {
+	// Classes with a constructor that takes no arguments are easy to construct
+	byClass := make(map[string][]constructorInfo)
+	hasDefault := make(map[string]bool)
+	for _, constructor := range info.Constructors {
+		byClass[constructor.Class] = append(byClass[constructor.Class], constructor)
+		if len(constructor.Params) == 0 {
+			hasDefault[constructor.Class] = true
+		}
+	}
+
+	var guidance []string
+	for _, class := range info.ClassNames() {
+		if snippet, ok := tg.rules.Fixtures.Construction[class]; ok {
+			guidance = append(guidance, fmt.Sprintf("Construct %s objects like this: %s", class, strings.TrimSpace(snippet)))
+			continue
+		}
+		if hasDefault[class] || len(byClass[class]) == 0 {
+			continue
+		}
+
+		// Suggest arguments for the constructor with the fewest parameters
+		constructor := byClass[class][0]
+		for _, candidate := range byClass[class][1:] {
+			if len(candidate.Params) < len(constructor.Params) {
+				constructor = candidate
+			}
+		}
+
+		var signature, arguments []string
+		for _, param := range constructor.Params {
+			signature = append(signature, strings.TrimSpace(param.Type+" "+param.Name))
+			arguments = append(arguments, defaultArgument(param.Type))
+		}
+		guidance = append(guidance, fmt.Sprintf("%s has no default constructor; it takes (%s), e.g. %s(%s)",
+			class, strings.Join(signature, ", "), class, strings.Join(arguments, ", ")))
+	}
+
+	return guidance
+}
+
+// defaultArgument suggests a simple valid argument for a parameter of the given type
+func defaultArgument(paramType string) string {
+	t := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(paramType), "const "))
+	switch {
+	case strings.Contains(t, "string") || strings.HasPrefix(t, "char*") || strings.HasPrefix(t, "char *"):
+		return `""`
+	case strings.Contains(t, "*"):
+		return "nullptr"
+	case strings.HasPrefix(t, "bool"):
+		return "false"
+	case strings.HasPrefix(t, "float") || strings.HasPrefix(t, "double"):
+		return "0.0"
+	case strings.HasPrefix(t, "int") || strings.HasPrefix(t, "long") || strings.HasPrefix(t, "short") ||
+		strings.HasPrefix(t, "unsigned") || strings.HasPrefix(t, "signed") || strings.HasPrefix(t, "char") ||
+		strings.Contains(t, "size_t") || strings.Contains(t, "int8_t") || strings.Contains(t, "int16_t") ||
+		strings.Contains(t, "int32_t") || strings.Contains(t, "int64_t"):
+		return "0"
+	default:
+		return "{}"
+	}
+}
+
+// assertionSeverityGuidance returns the prompt lines choosing between fatal and non-fatal assertions
+func (tg *TestGenerator) assertionSeverityGuidance() string {
+	var guidance strings.Builder
+	nonFatal, fatal := tg.framework.NonFatalFamily, tg.framework.FatalFamily
+
+	switch strings.ToLower(strings.TrimSpace(tg.rules.Assertions.DefaultSeverity)) {
+	case "expect":
+		guidance.WriteString(fmt.Sprintf("- Use non-fatal %s assertions by default, not %s\n", nonFatal, fatal))
+	case "assert":
+		guidance.WriteString(fmt.Sprintf("- Use fatal %s assertions by default, not %s\n", fatal, nonFatal))
+	}
+
+	if tg.rules.Assertions.FatalPreconditions {
+		guidance.WriteString(fmt.Sprintf("- Use %s for preconditions that the rest of the test depends on, and %s for the checks themselves\n", fatal, nonFatal))
+	}
+
+	if tg.rules.Assertions.UseMatchers && len(tg.framework.MatcherAssertions) > 0 {
+		guidance.WriteString(fmt.Sprintf("- Prefer matcher-based assertions (%s) over plain comparisons, e.g. %s\n",
+			strings.Join(tg.framework.MatcherAssertions, ", "), tg.framework.MatcherExample))
+	}
+
+	return guidance.String()
+}
+
+// registrationGuidance returns the prompt line asking to define every test with
+// Output.RegistrationMacro, shown with its configured usage
+func (tg *TestGenerator) registrationGuidance() string {
+	macro := tg.rules.Output.RegistrationMacro
+	name := strings.TrimSpace(macro.Name)
+	if name == "" {
+		return ""
+	}
+	usage := strings.TrimSpace(macro.Usage)
+	if usage == "" {
+		usage = name + "(SuiteName, TestName) { ... }"
+	}
+	return fmt.Sprintf("- Define every test with the %s macro instead of the framework's own test macros, "+
+		"which registers it automatically. Use it exactly like this: %s\n", name, usage)
+}
+
+// headerIncludes returns the framework's main include followed by the configured includes, without duplicates
+func (tg *TestGenerator) headerIncludes() []string {
+	var includes []string
+	seen := make(map[string]bool)
+
+	candidates := append([]string{tg.framework.MainInclude}, tg.rules.Includes...)
+	if tg.propertyBased {
+		candidates = append(candidates, tg.framework.PropertyInclude)
+	}
+	if tg.rules.Assertions.UseMatchers {
+		candidates = append(candidates, tg.framework.MatcherInclude)
+	}
+	if tg.rules.Fixtures.SharedHeader != "" {
+		candidates = append(candidates, fmt.Sprintf("#include \"%s\"", tg.rules.Fixtures.SharedHeader))
+	}
+
+	for _, include := range candidates {
+		// Compare ignoring spacing so "#include<x>" and "#include <x>" match
+		key := strings.Join(strings.Fields(strings.Replace(include, "#include", "#include ", 1)), " ")
+		if include == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		includes = append(includes, include)
+	}
+
+	return includes
+}
+
+// promptParts is a generation prompt split into the standing instructions (role and output
+// format) and the request for one file (requirements and the code to test)
+type promptParts struct {
+	System string
+	User   string
+}
+
+// combined joins both parts into a single prompt for the generate endpoint
+func (p promptParts) combined() string {
+	if p.System == "" {
+		return p.User
+	}
+	return p.System + "\n\n" + p.User
+}
+
 // generatePrompt creates the prompt for the LLM with stricter output requirements
-func (tg *TestGenerator) generatePrompt(code, methodsList, extraPrompt string, originalImports []string) string {
+func (tg *TestGenerator) generatePrompt(code string, info SourceInfo, methodsList, extraPrompt string, originalImports []string, freeFunctionsOnly bool, profile LanguageProfile) promptParts {
+	return promptParts{
+		System: tg.systemPrompt(),
+		User:   tg.userPrompt(code, info, methodsList, extraPrompt, originalImports, freeFunctionsOnly, profile),
+	}
+}
+
+// systemPrompt returns the role description and the output format requirements
+func (tg *TestGenerator) systemPrompt() string {
 	var prompt strings.Builder
 
 	// Role description
 	if tg.rules.LLMPromptGuidance.RoleDescription != "" {
 		prompt.WriteString(tg.rules.LLMPromptGuidance.RoleDescription)
-		prompt.WriteString("\n\n")
+		prompt.WriteString("\n")
 	}
 
+	// Strict output format requirements
+	locale := tg.promptLocale()
+	prompt.WriteString("\n" + locale.OutputRequirements + "\n")
+	prompt.WriteString(locale.OnlyCode + "\n")
+	prompt.WriteString(locale.NoExplanations + "\n")
+	prompt.WriteString(locale.NoPhrases + "\n")
+	prompt.WriteString(locale.StartWith + "\n")
+	prompt.WriteString(locale.EndWith + "\n")
+
+	if tg.rules.OutputFormat.MarkdownCodeFences {
+		prompt.WriteString(locale.UseFences + "\n")
+	} else {
+		prompt.WriteString(locale.NoFences + "\n")
+	}
+
+	return strings.TrimSpace(prompt.String())
+}
+
+// userPrompt returns the test requirements and the code to test
+func (tg *TestGenerator) userPrompt(code string, info SourceInfo, methodsList, extraPrompt string, originalImports []string, freeFunctionsOnly bool, profile LanguageProfile) string {
+	var prompt strings.Builder
+	locale := tg.promptLocale()
+
 	// Basic instruction with emphasis on output format
-	prompt.WriteString("Generate ONLY the C++ unit test code using ")
-	prompt.WriteString(tg.rules.TestFramework)
-	prompt.WriteString(" framework. Do not include any explanations, comments, or text outside the code.\n\n")
+	prompt.WriteString(fmt.Sprintf(locale.Generate, tg.rules.TestFramework))
+	prompt.WriteString("\n\n")
 
 	// Test requirements
-	prompt.WriteString("Requirements:\n")
+	prompt.WriteString(locale.Requirements + "\n")
 	prompt.WriteString(fmt.Sprintf("- Use C++ standard: %s\n", tg.rules.Standards.CPPStandard))
+	if profile.Language != cppProfile.Language {
+		prompt.WriteString(fmt.Sprintf("- The code under test is %s (%s), compiled separately with %s: ", profile.Language, profile.Standard, profile.Compiler))
+		prompt.WriteString("include its headers inside an extern \"C\" block and don't use classes or objects from it\n")
+	}
 	prompt.WriteString(fmt.Sprintf("- Include %d test cases per method\n", tg.rules.TestCaseRules.PerMethod))
+	if counts := tg.perMethodTestCounts(info); len(counts) > 0 {
+		prompt.WriteString("- Use these test case counts instead for the following methods: ")
+		prompt.WriteString(strings.Join(counts, ", "))
+		prompt.WriteString("\n")
+	}
 	prompt.WriteString(fmt.Sprintf("- Maximum total tests: %d\n", tg.rules.TestCaseRules.TotalTests))
 
 	if tg.rules.TestCaseRules.IncludePositive {
@@ -272,10 +1280,10 @@ func (tg *TestGenerator) generatePrompt(code, methodsList, extraPrompt string, o
 		prompt.WriteString("\n")
 	}
 
-	// Additional includes from config
-	if len(tg.rules.Includes) > 0 {
+	// Framework header plus additional includes from config
+	if includes := tg.headerIncludes(); len(includes) > 0 {
 		prompt.WriteString("- Also include these headers: ")
-		prompt.WriteString(strings.Join(tg.rules.Includes, ", "))
+		prompt.WriteString(strings.Join(includes, ", "))
 		prompt.WriteString("\n")
 	}
 
@@ -286,39 +1294,126 @@ func (tg *TestGenerator) generatePrompt(code, methodsList, extraPrompt string, o
 		prompt.WriteString("\n")
 	}
 
-	// Strict output format requirements
-	prompt.WriteString("\nIMPORTANT OUTPUT REQUIREMENTS:\n")
-	prompt.WriteString("- Return ONLY valid C++ test code\n")
-	prompt.WriteString("- Do NOT include any explanatory text\n")
-	prompt.WriteString("- Do NOT include phrases like 'Here is', 'This test', etc.\n")
-	prompt.WriteString("- Start directly with #include statements or TEST macros\n")
-	prompt.WriteString("- End with the last closing brace of the test\n")
+	// External dependencies replaced by stubs linked into the test binary
+	if len(tg.rules.Mocks.GlobalStubs) > 0 {
+		prompt.WriteString("- These external dependencies are stubbed by implementations linked into the test binary: ")
+		prompt.WriteString(strings.Join(tg.rules.Mocks.GlobalStubs, ", "))
+		prompt.WriteString(". Call the code under test normally and do not define, mock or re-implement them\n")
+	}
 
-	if tg.rules.OutputFormat.MarkdownCodeFences {
-		prompt.WriteString("- Use markdown code fences (```cpp and ```)\n")
-	} else {
-		prompt.WriteString("- Do NOT use markdown code fences\n")
+	// Assertion preferences translated to the selected framework's macros
+	if assertions := tg.framework.translateAssertions(tg.rules.Assertions.Preferred); len(assertions) > 0 {
+		prompt.WriteString("- Prefer these assertions: ")
+		prompt.WriteString(strings.Join(assertions, ", "))
+		prompt.WriteString("\n")
+	}
+
+	// Shared fixtures the generated tests should reuse
+	if tg.fixtures != "" {
+		prompt.WriteString(fmt.Sprintf("- Reuse the fixtures and helpers of the shared header %s instead of redefining them. Its content:\n", tg.rules.Fixtures.SharedHeader))
+		prompt.WriteString(tg.fixtures)
+		prompt.WriteString("\n")
+	}
+
+	// Teams with bespoke registration macros get tests in their own shape
+	if registration := tg.registrationGuidance(); registration != "" {
+		prompt.WriteString(registration)
+	}
+
+	// Consistent failure semantics for the generated assertions
+	if severity := tg.assertionSeverityGuidance(); severity != "" {
+		prompt.WriteString(severity)
+	}
+
+	if tg.rules.OutputFormat.TraceabilityComments {
+		prompt.WriteString("- Put a comment naming the covered function on the line before each test, e.g. // covers: Vector::normalize\n")
+	}
+
+	// White-box testing: function bodies show which paths the tests have to exercise
+	if tg.rules.LLMPromptGuidance.IncludeImplementation && len(info.Functions) > 0 {
+		prompt.WriteString("- The implementation is part of the code below, not just the interface. Read the function bodies and ")
+		prompt.WriteString("design tests that exercise every branch, error path and boundary check they contain, calling only the public interface\n")
+	}
+
+	// Models tend to test only the first class, so every class gets its own suite
+	if suites := tg.classSuiteGuidance(info); suites != "" {
+		prompt.WriteString(suites)
+	}
+
+	// Overloads listed under one name tend to get only one of them tested
+	if tg.rules.Naming.DisambiguateOverloads {
+		if overloads := overloadGuidance(info); overloads != "" {
+			prompt.WriteString(overloads)
+		}
+	}
+
+	// Exceptions are behavior too, plain value assertions never reach them
+	if tg.rules.TestCaseRules.TestExceptions {
+		if exceptions := tg.exceptionGuidance(info); exceptions != "" {
+			prompt.WriteString(exceptions)
+		}
+	}
+
+	// Protected methods are only reachable from a subclass
+	if protected := tg.protectedAccessGuidance(info); protected != "" {
+		prompt.WriteString(protected)
+	}
+
+	// Properties find the edge cases that hand-picked examples miss
+	if properties := tg.propertyGuidance(info); properties != "" {
+		prompt.WriteString(properties)
+	}
+
+	// Tests of code reading the clock are only deterministic if they control the time
+	if clock := tg.clockGuidance(info); clock != "" {
+		prompt.WriteString(clock)
+	}
+
+	// Realistic inputs for domain-specific functions
+	if samples := tg.sampleDataGuidance(info); samples != "" {
+		prompt.WriteString(samples)
+	}
+
+	// Valid construction for classes the model would otherwise guess arguments for
+	for _, line := range tg.constructionGuidance(info) {
+		prompt.WriteString("- " + line + "\n")
+	}
+
+	if freeFunctionsOnly {
+		prompt.WriteString("- The code contains only free functions and no classes: call each function directly, ")
+		prompt.WriteString("without creating objects or fixtures, and do not test constructors, destructors or operators\n")
 	}
 
 	// Add extra prompt if provided
 	if extraPrompt != "" {
-		prompt.WriteString("\nAdditional requirements:\n")
+		prompt.WriteString("\n" + locale.AdditionalRequirements + "\n")
 		prompt.WriteString(extraPrompt)
 		prompt.WriteString("\n")
 	}
 
+	// Demonstration of the expected test style
+	if tg.rules.OutputFormat.ExampleInPrompt {
+		if source, test := tg.promptExample(); source != "" && test != "" {
+			prompt.WriteString("\n" + locale.Example + "\n")
+			prompt.WriteString(source)
+			prompt.WriteString("\n\n" + locale.ExampleTests + "\n")
+			prompt.WriteString(test)
+			prompt.WriteString("\n")
+		}
+	}
+
 	// Add the code to test
-	prompt.WriteString("\nCode to test:\n")
+	prompt.WriteString("\n" + locale.CodeToTest + "\n")
 	if tg.rules.OutputFormat.MarkdownCodeFences {
 		prompt.WriteString("```cpp\n")
 	}
-	prompt.WriteString(code)
+	prompt.WriteString(tg.promptCode(code))
 	if tg.rules.OutputFormat.MarkdownCodeFences {
 		prompt.WriteString("\n```")
 	}
 
 	// Final instruction
-	prompt.WriteString("\n\nOutput only the complete C++ test file code:")
+	prompt.WriteString("\n\n" + locale.OutputOnly)
 	if tg.rules.OutputFormat.MarkdownCodeFences {
 		prompt.WriteString("\n```cpp")
 	}
@@ -362,8 +1457,10 @@ func (tg *TestGenerator) postProcessResponse(response string) string {
 }
 
 // tryModelsWithRetries tries multiple models with retry logic
-func (tg *TestGenerator) tryModelsWithRetries(req api.GenerateRequest, modelsToTry []string, methods []string) (string, error) {
+func (tg *TestGenerator) tryModelsWithRetries(ctx context.Context, req api.GenerateRequest, modelsToTry []string, methods []string) (string, modelMetrics, error) {
 	var lastErr error
+	var lastResponse string
+	var metrics modelMetrics
 
 	for _, model := range modelsToTry {
 		req.Model = model
@@ -373,50 +1470,168 @@ func (tg *TestGenerator) tryModelsWithRetries(req api.GenerateRequest, modelsToT
 		for attempt := 1; attempt <= tg.rules.ModelConfig.MaxRetries; attempt++ {
 			log.Printf("Attempt %d/%d with model %s", attempt, tg.rules.ModelConfig.MaxRetries, model)
 
-			result, err := tg.callModel(req)
+			if ctx.Err() != nil {
+				return "", metrics, ctx.Err()
+			}
+
+			result, callMetrics, err := tg.callModel(ctx, req)
+			metrics.add(callMetrics)
 			if err == nil {
 				log.Printf("Successfully generated tests with model %s on attempt %d", model, attempt)
-				return result, nil
+				metrics.Model = model
+				return result, metrics, nil
 			}
 
 			lastErr = err
+			var genErr *generationError
+			if errors.As(err, &genErr) {
+				lastResponse = genErr.LastResponse
+			}
 			log.Printf("Attempt %d failed with model %s: %v", attempt, model, err)
 
+			// Permanent errors won't go away on retry, move on to the next model
+			if !tg.isRetryableError(err) {
+				log.Printf("Error is not retryable, skipping remaining attempts for model %s", model)
+				break
+			}
+
 			// Wait before retry (exponential backoff)
 			if attempt < tg.rules.ModelConfig.MaxRetries {
-				waitTime := time.Duration(attempt) * time.Second
+				waitTime := tg.retryDelay(attempt)
 				log.Printf("Waiting %v before retry", waitTime)
-				time.Sleep(waitTime)
+				select {
+				case <-time.After(waitTime):
+				case <-ctx.Done():
+					return "", metrics, ctx.Err()
+				}
 			}
 		}
 
 		log.Printf("All attempts failed for model %s", model)
 	}
 
-	return "", fmt.Errorf("failed to generate tests with all models. Last error: %v", lastErr)
+	return "", metrics, &generationError{
+		Err:          fmt.Errorf("failed to generate tests with all models. Last error: %w", lastErr),
+		LastResponse: lastResponse,
+	}
+}
+
+// errEmptyResponse is a model call that returned no text
+var errEmptyResponse = errors.New("empty response from model")
+
+// generationError is a failed generation together with the last raw response the model gave, if any
+type generationError struct {
+	Err          error
+	LastResponse string
+}
+
+func (e *generationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *generationError) Unwrap() error {
+	return e.Err
+}
+
+// isRetryableError checks whether an error is worth another attempt. Responses rejected by a
+// validator always are and take precedence over ModelConfig.RetryableErrors, which only decides
+// about the other errors by substring.
+func (tg *TestGenerator) isRetryableError(err error) bool {
+	retryableErrors := tg.rules.ModelConfig.RetryableErrors
+	if len(retryableErrors) == 0 {
+		retryableErrors = DefaultRetryableErrors
+	}
+
+	// A rejected response may well pass on the next attempt
+	var rejected *validationError
+	if errors.As(err, &rejected) {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, substring := range retryableErrors {
+		if strings.Contains(message, strings.ToLower(substring)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryDelay returns how long to wait after the given failed attempt, capped at
+// ModelConfig.MaxRetryDelay seconds
+func (tg *TestGenerator) retryDelay(attempt int) time.Duration {
+	waitTime := time.Duration(attempt) * time.Second
+	maxDelay := time.Duration(tg.rules.ModelConfig.MaxRetryDelay) * time.Second
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+	if waitTime > maxDelay {
+		waitTime = maxDelay
+	}
+	return waitTime
 }
 
 // callModel makes the actual API call to the model
-func (tg *TestGenerator) callModel(req api.GenerateRequest) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(),
+func (tg *TestGenerator) callModel(ctx context.Context, req api.GenerateRequest) (string, modelMetrics, error) {
+	// Waiting for the rate limit or a free request slot doesn't count against the request timeout
+	config := tg.rules.ModelConfig
+	if err := waitForHostRate(ctx, ollamaHost(), config.RequestsPerMinute, config.RequestBurst); err != nil {
+		return "", modelMetrics{}, err
+	}
+	// Shared servers are easily overloaded, so requests to a host are throttled
+	release, err := acquireHostSlot(ctx, ollamaHost(), config.MaxConcurrentRequests)
+	if err != nil {
+		return "", modelMetrics{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx,
 		time.Duration(tg.rules.ModelConfig.TimeoutMinutes)*time.Minute)
 	defer cancel()
 
 	var result strings.Builder
-
-	err := tg.client.Generate(ctx, &req, func(resp api.GenerateResponse) error {
-		result.WriteString(resp.Response)
-		return nil
-	})
-
+	var metrics modelMetrics
+	// Small models can get stuck repeating a line until num_predict runs out
+	repetition := newRepetitionDetector(config.RepetitionThreshold)
+
+	if tg.useChatAPI() {
+		chatReq := chatRequestFrom(req)
+		err = tg.client.Chat(ctx, &chatReq, func(resp api.ChatResponse) error {
+			result.WriteString(resp.Message.Content)
+			// Token counts and timing arrive with the final response
+			if resp.Done {
+				metrics = metricsFromResponse(resp.Metrics)
+			}
+			return repetition.write(resp.Message.Content)
+		})
+	} else {
+		err = tg.client.Generate(ctx, &req, func(resp api.GenerateResponse) error {
+			result.WriteString(resp.Response)
+			if resp.Done {
+				metrics = metricsFromResponse(resp.Metrics)
+			}
+			return repetition.write(resp.Response)
+		})
+	}
+	release()
+
+	// A stuck attempt is cut short and retried like a rejected response
+	if errors.Is(err, errRepeatingOutput) {
+		fmt.Printf("🔁 %s started repeating itself after %d bytes, aborting the attempt\n", req.Model, result.Len())
+		return "", metrics, &generationError{
+			Err:          &validationError{Validator: "repetition", Err: err},
+			LastResponse: result.String(),
+		}
+	}
 	if err != nil {
-		return "", fmt.Errorf("API call failed: %v", err)
+		return "", metrics, fmt.Errorf("API call failed: %v", err)
 	}
 
 	response := result.String()
 	if response == "" {
-		return "", fmt.Errorf("empty response from model")
+		return "", metrics, errEmptyResponse
 	}
+	rawResponse := response
 
 	log.Printf("Raw response length: %d bytes", len(response))
 
@@ -430,17 +1645,69 @@ func (tg *TestGenerator) callModel(req api.GenerateRequest) (string, error) {
 	// Final cleanup
 	response = strings.TrimSpace(response)
 
-	// Validate that we have actual C++ code
-	if !tg.isValidCppCode(response) {
-		return "", fmt.Errorf("response does not contain valid C++ code")
+	// Validate that we have actual test code
+	if err := tg.validateResponse(response); err != nil {
+		return "", metrics, &generationError{Err: err, LastResponse: rawResponse}
 	}
 
 	log.Printf("Final cleaned response length: %d bytes", len(response))
-	return response, nil
+	return response, metrics, nil
+}
+
+// useChatAPI reports whether requests go through the chat endpoint instead of generate
+func (tg *TestGenerator) useChatAPI() bool {
+	return strings.EqualFold(strings.TrimSpace(tg.rules.ModelConfig.APIMode), "chat")
 }
 
-// isValidCppCode performs basic validation that the response contains C++ code
+// chatRequestFrom converts a generate request into the equivalent chat request, with the
+// system prompt and the prompt as separate messages
+func chatRequestFrom(req api.GenerateRequest) api.ChatRequest {
+	var messages []api.Message
+	if req.System != "" {
+		messages = append(messages, api.Message{Role: "system", Content: req.System})
+	}
+	messages = append(messages, api.Message{Role: "user", Content: req.Prompt})
+
+	return api.ChatRequest{
+		Model:     req.Model,
+		Messages:  messages,
+		Options:   req.Options,
+		KeepAlive: req.KeepAlive,
+	}
+}
+
+// checkResponseSize rejects degenerate responses below the configured minimum size and
+// warns about likely runaway output above the maximum
+func (tg *TestGenerator) checkResponseSize(code string) error {
+	limits := tg.rules.OutputFormat
+	size := len(code)
+	lines := strings.Count(code, "\n") + 1
+
+	if limits.MinBytes > 0 && size < limits.MinBytes {
+		return fmt.Errorf("response too short: %d bytes (minimum %d)", size, limits.MinBytes)
+	}
+	if limits.MinLines > 0 && lines < limits.MinLines {
+		return fmt.Errorf("response too short: %d lines (minimum %d)", lines, limits.MinLines)
+	}
+
+	if limits.MaxBytes > 0 && size > limits.MaxBytes {
+		fmt.Printf("⚠️  Generated test is %d bytes (maximum %d), the model may have produced runaway output\n", size, limits.MaxBytes)
+	}
+	if limits.MaxLines > 0 && lines > limits.MaxLines {
+		fmt.Printf("⚠️  Generated test is %d lines (maximum %d), the model may have produced runaway output\n", lines, limits.MaxLines)
+	}
+
+	return nil
+}
+
+// isValidCppCode performs basic validation that the response contains C++ code. With
+// OutputFormat.StrictValidation it has to define a test with one of the framework's macros.
 func (tg *TestGenerator) isValidCppCode(code string) bool {
+	if tg.rules.OutputFormat.StrictValidation {
+		// Macros named in comments or prose strings don't make a test
+		return tg.checkFrameworkMacros(stripCommentsAndStrings(code)) == nil
+	}
+
 	// Must contain at least one of these C++ patterns
 	requiredPatterns := []string{
 		"#include",
@@ -454,6 +1721,11 @@ func (tg *TestGenerator) isValidCppCode(code string) bool {
 			return true
 		}
 	}
+	for _, macro := range tg.framework.TestMacros {
+		if strings.Contains(code, macro+"(") {
+			return true
+		}
+	}
 
 	return false
 }
@@ -463,9 +1735,16 @@ func (tg *TestGenerator) extractCodeFromMarkdown(content string) string {
 	// Debug: log what we're processing
 	log.Printf("Extracting code from markdown. Content starts with: %.50s", content)
 
-	// Look for markdown code blocks and extract content between them
+	// Prefer blocks explicitly tagged as C/C++ over untagged or prose blocks
+	if taggedCode := tg.extractTaggedCodeBlocks(content); taggedCode != "" {
+		log.Printf("Extracted language-tagged code length: %d bytes", len(taggedCode))
+		return taggedCode
+	}
+
+	// Look for markdown code blocks and collect each one in order
 	lines := strings.Split(content, "\n")
-	var codeLines []string
+	var blocks []string
+	var blockLines []string
 	insideCodeBlock := false
 
 	for i, line := range lines {
@@ -476,29 +1755,31 @@ func (tg *TestGenerator) extractCodeFromMarkdown(content string) string {
 			if !insideCodeBlock {
 				// Starting a code block
 				insideCodeBlock = true
+				blockLines = nil
 				log.Printf("Found code block start at line %d: %s", i, trimmedLine)
-				continue
 			} else {
 				// Ending a code block
 				insideCodeBlock = false
+				blocks = append(blocks, strings.Join(blockLines, "\n"))
 				log.Printf("Found code block end at line %d: %s", i, trimmedLine)
-				continue
 			}
+			continue
 		}
 
-		// If we're inside a code block, collect the line
+		// Only lines inside a code block are code once any block exists
 		if insideCodeBlock {
-			codeLines = append(codeLines, line)
-		} else if !strings.HasPrefix(trimmedLine, "```") {
-			// If we're not in a code block and this isn't a fence marker,
-			// it might be plain code without markdown
-			codeLines = append(codeLines, line)
+			blockLines = append(blockLines, line)
 		}
 	}
 
+	// Keep an unterminated block, models often stop before the closing fence
+	if insideCodeBlock {
+		blocks = append(blocks, strings.Join(blockLines, "\n"))
+	}
+
 	// If we didn't find any code blocks, return the original content
 	// after removing any stray markdown fence markers
-	if len(codeLines) == 0 {
+	if len(blocks) == 0 {
 		log.Printf("No code blocks found, cleaning fence markers from original content")
 		result := content
 		result = strings.ReplaceAll(result, "```cpp", "")
@@ -507,16 +1788,54 @@ func (tg *TestGenerator) extractCodeFromMarkdown(content string) string {
 		return strings.TrimSpace(result)
 	}
 
-	// Join the code lines
-	result := strings.Join(codeLines, "\n")
-
-	// Remove leading and trailing empty lines
-	result = strings.TrimSpace(result)
+	// Concatenate the blocks, dropping any prose between them
+	result := strings.TrimSpace(strings.Join(blocks, "\n\n"))
 
 	log.Printf("Extracted code length: %d bytes", len(result))
 	return result
 }
 
+// extractTaggedCodeBlocks concatenates the fenced blocks tagged cpp, c++ or c, or returns ""
+// when the content contains no such block
+func (tg *TestGenerator) extractTaggedCodeBlocks(content string) string {
+	codeTags := map[string]bool{"cpp": true, "c++": true, "c": true}
+
+	var blocks []string
+	var current []string
+	insideCodeBlock := false
+	isCodeTagged := false
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmedLine := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmedLine, "```") {
+			if !insideCodeBlock {
+				tag := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(trimmedLine, "```")))
+				isCodeTagged = codeTags[tag]
+				insideCodeBlock = true
+				current = nil
+			} else {
+				if isCodeTagged {
+					blocks = append(blocks, strings.Join(current, "\n"))
+				}
+				insideCodeBlock = false
+			}
+			continue
+		}
+
+		if insideCodeBlock {
+			current = append(current, line)
+		}
+	}
+
+	// Keep an unterminated tagged block, models often stop before the closing fence
+	if insideCodeBlock && isCodeTagged {
+		blocks = append(blocks, strings.Join(current, "\n"))
+	}
+
+	return strings.TrimSpace(strings.Join(blocks, "\n\n"))
+}
+
 func (tg *TestGenerator) extractImportsFromCode(code string) []string {
 	var imports []string
 	lines := strings.Split(code, "\n")
@@ -531,13 +1850,14 @@ func (tg *TestGenerator) extractImportsFromCode(code string) []string {
 	return imports
 }
 
-// generateTestFilename generates the test filename based on the source file, preserving folder structure
+// generateTestFilename generates the test filename based on the source file, preserving the
+// folder structure below the scanned directory
 func (tg *TestGenerator) generateTestFilename(sourceFile string) string {
-	// Get the relative path from the codebase directory
-	relPath, err := filepath.Rel(tg.rules.Paths.CodebaseDir, sourceFile)
-	if err != nil {
-		// If we can't get relative path, just use the base name
-		log.Printf("Warning: Could not get relative path for %s: %v", sourceFile, err)
+	// Get the relative path from the directory the sources were read from
+	relPath, err := filepath.Rel(tg.rules.ScannedDir(), sourceFile)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		// Outside the scanned directory the test would land outside tests_dir, so just use the base name
+		log.Printf("Warning: %s is not under %s, naming its test after the base name", sourceFile, tg.rules.ScannedDir())
 		baseName := filepath.Base(sourceFile)
 		return tg.convertToTestFilename(baseName)
 	}
@@ -559,20 +1879,31 @@ func (tg *TestGenerator) generateTestFilename(sourceFile string) string {
 
 // convertToTestFilename converts a source filename to test filename
 func (tg *TestGenerator) convertToTestFilename(filename string) string {
-	if strings.HasSuffix(filename, ".cpp") {
-		return strings.Replace(filename, ".cpp", "_test.cc", 1)
-	} else if strings.HasSuffix(filename, ".cc") {
-		return strings.Replace(filename, ".cc", "_test.cc", 1)
-	} else if strings.HasSuffix(filename, ".h") {
-		return strings.Replace(filename, ".h", "_test.cc", 1)
-	} else if strings.HasSuffix(filename, ".hpp") {
-		return strings.Replace(filename, ".hpp", "_test.cc", 1)
-	}
-	return filename + "_test.cc"
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + "_test.cc"
 }
 
-// saveTestFile saves the generated test code to a file
+// saveTestFile saves the generated test code to a file and counts it as a test written this run
 func (tg *TestGenerator) saveTestFile(outputPath, testCode string) error {
+	if err := tg.writeGeneratedFile(outputPath, testCode); err != nil {
+		return err
+	}
+	tg.recordWrittenFile(outputPath)
+	return nil
+}
+
+// writeGeneratedFile writes a file with the generated marker, refusing to replace hand-written ones
+func (tg *TestGenerator) writeGeneratedFile(outputPath, testCode string) error {
+	if err := tg.checkOverwrite(outputPath); err != nil {
+		return err
+	}
+	if !strings.Contains(testCode, generatedMarker) {
+		header := generatedMarker + "\n"
+		if banner := tg.bannerFor(outputPath); banner != "" {
+			header += banner + "\n"
+		}
+		testCode = header + testCode
+	}
+
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -580,6 +1911,7 @@ func (tg *TestGenerator) saveTestFile(outputPath, testCode string) error {
 	}
 
 	// Write the test code to file
+	testCode = normalizeLineEndings(testCode, lineEndingStyle(tg.rules.OutputFormat.LineEndings))
 	if err := os.WriteFile(outputPath, []byte(testCode), 0644); err != nil {
 		return fmt.Errorf("failed to write test file %s: %v", outputPath, err)
 	}
@@ -587,3 +1919,98 @@ func (tg *TestGenerator) saveTestFile(outputPath, testCode string) error {
 	log.Printf("Successfully saved test file: %s", outputPath)
 	return nil
 }
+
+// generatedMarkerText marks every file the generator writes, so it knows which files it may overwrite
+const generatedMarkerText = "AUTOGENERATED by utg"
+
+// generatedMarker is the marker as a C++ comment heading generated tests
+const generatedMarker = "// " + generatedMarkerText
+
+// checkOverwrite refuses to overwrite an existing file without the generated marker, which
+// is most likely a hand-written test, unless Force is set or manifest.json lists it as generated
+func (tg *TestGenerator) checkOverwrite(path string) error {
+	if tg.options.Force {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	if !strings.Contains(string(data), generatedMarkerText) && !tg.manifestListsTest(path) {
+		return fmt.Errorf("refusing to overwrite %s: it was not generated by utg (use -force to overwrite it)", path)
+	}
+	return nil
+}
+
+// analysisKey identifies an analyzed code block by the file it came from and a hash of it
+type analysisKey struct {
+	Name string
+	Hash [sha256.Size]byte
+}
+
+// sourceInfo returns the analysis of code from the file name, analyzing each version of a
+// file only once per run
+func (tg *TestGenerator) sourceInfo(name, code string) SourceInfo {
+	key := analysisKey{Name: name, Hash: sha256.Sum256([]byte(code))}
+
+	tg.analysesMu.Lock()
+	defer tg.analysesMu.Unlock()
+
+	if info, ok := tg.analyses[key]; ok {
+		return info
+	}
+	if tg.analyses == nil {
+		tg.analyses = make(map[analysisKey]SourceInfo)
+	}
+	info := AnalyzeSource(code)
+	tg.analyses[key] = info
+	return info
+}
+
+// resetSourceInfo drops the analyses of an earlier run, whose files may have changed since
+func (tg *TestGenerator) resetSourceInfo() {
+	tg.analysesMu.Lock()
+	defer tg.analysesMu.Unlock()
+	tg.analyses = nil
+}
+
+// recordWrittenFile remembers that the test at path was saved during this run
+func (tg *TestGenerator) recordWrittenFile(path string) {
+	tg.writtenMu.Lock()
+	defer tg.writtenMu.Unlock()
+	tg.written = appendUnique(tg.written, path)
+}
+
+// recordSupportFile remembers a file written alongside the tests during this run, such as the
+// Makefile or the manifest, which is committed with them but not counted as a test
+func (tg *TestGenerator) recordSupportFile(path string) {
+	tg.writtenMu.Lock()
+	defer tg.writtenMu.Unlock()
+	tg.supportFiles = appendUnique(tg.supportFiles, path)
+}
+
+// appendUnique appends path to paths unless it is already there
+func appendUnique(paths []string, path string) []string {
+	for _, existing := range paths {
+		if existing == path {
+			return paths
+		}
+	}
+	return append(paths, path)
+}
+
+// WrittenFiles returns the tests saved during this run
+func (tg *TestGenerator) WrittenFiles() []string {
+	tg.writtenMu.Lock()
+	defer tg.writtenMu.Unlock()
+
+	return append([]string(nil), tg.written...)
+}
+
+// SupportFiles returns the files written alongside the tests during this run
+func (tg *TestGenerator) SupportFiles() []string {
+	tg.writtenMu.Lock()
+	defer tg.writtenMu.Unlock()
+
+	return append([]string(nil), tg.supportFiles...)
+}
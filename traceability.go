@@ -0,0 +1,101 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Matches a traceability comment such as "// covers: Vector::normalize"
+var coversCommentPattern = regexp.MustCompile(`//\s*covers:`)
+
+// testDefinitionPattern matches the start of a test defined with one of the framework's test
+// macros and captures its arguments, e.g. `Suite, Name` or `"name", "[tag]"`
+func testDefinitionPattern(macros []string) *regexp.Regexp {
+	quoted := make([]string, len(macros))
+	for i, macro := range macros {
+		quoted[i] = regexp.QuoteMeta(macro)
+	}
+	return regexp.MustCompile(`^\s*(?:` + strings.Join(quoted, "|") + `)\s*\((.*)`)
+}
+
+// traceabilityTargets returns the functions of the code under test that tests can be traced
+// to, longest names first so the most specific match wins
+func traceabilityTargets(info SourceInfo) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, name := range info.FreeFunctions {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, function := range info.Functions {
+		if !seen[function.Name] {
+			seen[function.Name] = true
+			names = append(names, function.Name)
+		}
+	}
+
+	sort.SliceStable(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	return names
+}
+
+// ensureTraceabilityComments adds a "// covers: <function>" comment above every test that
+// lacks one, guessing the function from the test's name and body. Tests where no function
+// of the code under test can be found are left untouched. Tests are found by the given test
+// macros of the framework.
+func ensureTraceabilityComments(testCode string, targets []string, testMacros []string) string {
+	if len(testMacros) == 0 {
+		return testCode
+	}
+	testMacroPattern := testDefinitionPattern(testMacros)
+	lines := strings.Split(testCode, "\n")
+	var out []string
+
+	for i, line := range lines {
+		if !testMacroPattern.MatchString(line) || hasCoversComment(out) {
+			out = append(out, line)
+			continue
+		}
+
+		// The test body runs until the next test definition
+		end := i + 1
+		for end < len(lines) && !testMacroPattern.MatchString(lines[end]) {
+			end++
+		}
+
+		if target := guessCoveredFunction(testMacroPattern, lines[i:end], targets); target != "" {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			out = append(out, indent+"// covers: "+target)
+		}
+		out = append(out, line)
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// hasCoversComment reports whether the last non-empty line so far is a traceability comment
+func hasCoversComment(lines []string) bool {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if trimmed := strings.TrimSpace(lines[i]); trimmed != "" {
+			return coversCommentPattern.MatchString(trimmed)
+		}
+	}
+	return false
+}
+
+// guessCoveredFunction finds the first target whose name appears in the test name or as a call in its body
+func guessCoveredFunction(testMacroPattern *regexp.Regexp, testLines []string, targets []string) string {
+	match := testMacroPattern.FindStringSubmatch(testLines[0])
+	testName := strings.ToLower(match[1])
+	body := strings.Join(testLines[1:], "\n")
+
+	for _, target := range targets {
+		short := target[strings.LastIndex(target, ":")+1:]
+		if strings.Contains(testName, strings.ToLower(short)) || strings.Contains(body, short+"(") {
+			return target
+		}
+	}
+	return ""
+}
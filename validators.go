@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// ResponseValidator checks a cleaned model response before it is accepted. An error rejects
+// the response; it is retried and the error is reported as the reason.
+type ResponseValidator interface {
+	Name() string
+	Validate(code string) error
+}
+
+// validatorFunc adapts a function to the ResponseValidator interface
+type validatorFunc struct {
+	name     string
+	validate func(code string) error
+}
+
+func (v validatorFunc) Name() string               { return v.name }
+func (v validatorFunc) Validate(code string) error { return v.validate(code) }
+
+// validationError is a response rejected by a validator
+type validationError struct {
+	Validator string
+	Err       error
+}
+
+func (e *validationError) Error() string {
+	return e.Err.Error()
+}
+
+// defaultValidators are run when OutputFormat.Validators is empty
+var defaultValidators = []string{"cpp_code", "size", "empty_assertions"}
+
+// builtinValidator returns the built-in validator with the given name
+func (tg *TestGenerator) builtinValidator(name string) (ResponseValidator, bool) {
+	switch name {
+	case "cpp_code":
+		return validatorFunc{name, func(code string) error {
+			if !tg.isValidCppCode(code) {
+				if tg.rules.OutputFormat.StrictValidation {
+					return fmt.Errorf("response defines no test with the %s macros %s", tg.framework.Name, strings.Join(tg.framework.TestMacros, ", "))
+				}
+				return fmt.Errorf("response does not contain valid C++ code")
+			}
+			return nil
+		}}, true
+	case "size":
+		return validatorFunc{name, tg.checkResponseSize}, true
+	case "brace_balance":
+		return validatorFunc{name, checkBraceBalance}, true
+	case "assertions":
+		return validatorFunc{name, tg.checkAssertions}, true
+	case "framework_macros":
+		return validatorFunc{name, tg.checkFrameworkMacros}, true
+	case "required_includes":
+		return validatorFunc{name, tg.checkRequiredIncludes}, true
+	case "empty_assertions":
+		return validatorFunc{name, checkEmptyAssertions}, true
+	case "tautologies":
+		return validatorFunc{name, checkTautologies}, true
+	}
+	return nil, false
+}
+
+// buildValidators returns the validation chain configured in OutputFormat.Validators
+func (tg *TestGenerator) buildValidators() []ResponseValidator {
+	names := tg.rules.OutputFormat.Validators
+	if len(names) == 0 {
+		names = defaultValidators
+	}
+
+	// Retrying tautologies is the same as listing their validator
+	if tg.tautologiesMode() == tautologiesRetry {
+		names = append(append([]string{}, names...), "tautologies")
+	}
+
+	var validators []ResponseValidator
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		validator, ok := tg.builtinValidator(name)
+		if !ok {
+			log.Printf("Unknown response validator %q, ignoring it", name)
+			continue
+		}
+		validators = append(validators, validator)
+	}
+	return validators
+}
+
+// validateResponse runs the validation chain and returns the first rejection
+func (tg *TestGenerator) validateResponse(code string) error {
+	for _, validator := range tg.validators {
+		if err := validator.Validate(code); err != nil {
+			log.Printf("Response rejected by validator %s: %v", validator.Name(), err)
+			return &validationError{Validator: validator.Name(), Err: err}
+		}
+	}
+	return nil
+}
+
+// checkBraceBalance rejects code whose braces, outside comments and literals, don't pair up,
+// which usually means the response was cut off
+func checkBraceBalance(code string) error {
+	depth := 0
+	for _, c := range stripCommentsAndStrings(code) {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("unbalanced braces: unexpected closing brace")
+			}
+		}
+	}
+	if depth > 0 {
+		return fmt.Errorf("unbalanced braces: %d left unclosed, the response may be truncated", depth)
+	}
+	return nil
+}
+
+// checkAssertions rejects code without any assertion of the configured framework. Matcher
+// assertions such as EXPECT_THAT and CHECK_THAT share their family's prefix, so they count too.
+func (tg *TestGenerator) checkAssertions(code string) error {
+	for _, family := range []string{tg.framework.NonFatalFamily, tg.framework.FatalFamily} {
+		if prefix := strings.TrimSuffix(family, "*"); prefix != "" && strings.Contains(code, prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("response contains no %s or %s assertions", tg.framework.NonFatalFamily, tg.framework.FatalFamily)
+}
+
+// checkFrameworkMacros rejects code that defines no test case with the configured framework's macros
+func (tg *TestGenerator) checkFrameworkMacros(code string) error {
+	if tg.testCasePattern.MatchString(code) {
+		return nil
+	}
+	return fmt.Errorf("response defines no %s test cases", tg.framework.Name)
+}
+
+// checkRequiredIncludes rejects code that doesn't include the framework's main header
+func (tg *TestGenerator) checkRequiredIncludes(code string) error {
+	header := strings.TrimSpace(strings.TrimPrefix(tg.framework.MainInclude, "#include"))
+	for _, include := range AnalyzeSource(code).Includes {
+		if include == header {
+			return nil
+		}
+	}
+	return fmt.Errorf("response is missing the required include %s", header)
+}
+
+// assertionArity is the number of arguments each assertion macro needs to test anything
+var assertionArity = map[string]int{
+	"EXPECT_TRUE": 1, "EXPECT_FALSE": 1, "ASSERT_TRUE": 1, "ASSERT_FALSE": 1,
+	"EXPECT_EQ": 2, "EXPECT_NE": 2, "EXPECT_LT": 2, "EXPECT_LE": 2, "EXPECT_GT": 2, "EXPECT_GE": 2,
+	"ASSERT_EQ": 2, "ASSERT_NE": 2, "ASSERT_LT": 2, "ASSERT_LE": 2, "ASSERT_GT": 2, "ASSERT_GE": 2,
+	"EXPECT_STREQ": 2, "EXPECT_STRNE": 2, "EXPECT_STRCASEEQ": 2, "EXPECT_STRCASENE": 2,
+	"ASSERT_STREQ": 2, "ASSERT_STRNE": 2, "ASSERT_STRCASEEQ": 2, "ASSERT_STRCASENE": 2,
+	"EXPECT_FLOAT_EQ": 2, "EXPECT_DOUBLE_EQ": 2, "ASSERT_FLOAT_EQ": 2, "ASSERT_DOUBLE_EQ": 2,
+	"EXPECT_NEAR": 3, "ASSERT_NEAR": 3,
+	"EXPECT_THROW": 2, "ASSERT_THROW": 2, "EXPECT_NO_THROW": 1, "ASSERT_NO_THROW": 1,
+	"EXPECT_ANY_THROW": 1, "ASSERT_ANY_THROW": 1, "EXPECT_THAT": 2, "ASSERT_THAT": 2,
+	"CHECK": 1, "CHECK_FALSE": 1, "REQUIRE": 1, "REQUIRE_FALSE": 1,
+	"CHECK_THAT": 2, "REQUIRE_THAT": 2, "CHECK_THROWS": 1, "REQUIRE_THROWS": 1,
+	"CHECK_THROWS_AS": 2, "REQUIRE_THROWS_AS": 2, "CHECK_NOTHROW": 1, "REQUIRE_NOTHROW": 1,
+}
+
+// assertionCallPattern matches the start of a macro call such as EXPECT_EQ(
+var assertionCallPattern = regexp.MustCompile(`\b([A-Z][A-Z_]*)\s*\(`)
+
+// checkEmptyAssertions rejects code with assertions that are missing their expected value or
+// condition, such as EXPECT_EQ(foo()) or REQUIRE(), which test nothing even when they compile
+func checkEmptyAssertions(code string) error {
+	stripped := stripCommentsAndStrings(code)
+	for _, match := range assertionCallPattern.FindAllStringSubmatchIndex(stripped, -1) {
+		macro := stripped[match[2]:match[3]]
+		arity, ok := assertionArity[macro]
+		if !ok {
+			continue
+		}
+
+		args, ok := macroArguments(stripped[match[1]:])
+		if !ok {
+			continue
+		}
+		if len(args) < arity {
+			return fmt.Errorf("assertion %s(%s) is missing an expected value", macro, strings.Join(args, ", "))
+		}
+	}
+	return nil
+}
+
+// macroArguments splits the arguments of a macro call at its top-level commas; code starts
+// just after the opening parenthesis. Empty arguments are dropped.
+func macroArguments(code string) ([]string, bool) {
+	var args []string
+	depth, start := 0, 0
+	for i := 0; i < len(code); i++ {
+		switch code[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth == 0 {
+				if arg := strings.TrimSpace(code[start:i]); arg != "" {
+					args = append(args, arg)
+				}
+				return args, true
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				if arg := strings.TrimSpace(code[start:i]); arg != "" {
+					args = append(args, arg)
+				}
+				start = i + 1
+			}
+		}
+	}
+	return nil, false
+}